@@ -0,0 +1,421 @@
+package aggretastic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/olivere/elastic/v7"
+)
+
+var ErrInvalidBucketsPath = fmt.Errorf("invalid buckets_path")
+
+// Special names of buckets_path
+const (
+	BucketsPathCount       = "_count"
+	BucketsPathKey         = "_key"
+	BucketsPathBucketCount = "_bucket_count"
+)
+
+// BucketsPathElement is an aggregation step of buckets_path
+type BucketsPathElement struct {
+	// Name is the name of aggregation
+	Name string
+
+	// Key selects a bucket of multi-bucket aggregation: `sale_type['hat']`.
+	// It's kept as is (with quotes)
+	Key   string
+	Keyed bool
+}
+
+// ParsedBucketsPath is the parsed buckets_path of pipeline aggregation:
+//
+//	AGG_NAME[KEY]? ( > AGG_NAME[KEY]? )* ( .PROPERTY | [PROPERTY] )?
+//
+// e.g. "sales>total", "sale_type['hat']>sales", "the_stats.avg", "the_percentiles[99.9]", "the_terms._bucket_count", "_count"
+type ParsedBucketsPath struct {
+	Elements []BucketsPathElement
+
+	// Property is the property of multi-value metric (or `_bucket_count`) which follows the last element
+	Property string
+
+	// bracketProperty is true when the property is given in brackets: `the_percentiles[99.9]`
+	bracketProperty bool
+}
+
+// ParseBucketsPath parses the buckets_path syntax the same way Elasticsearch does
+func ParseBucketsPath(path string) (*ParsedBucketsPath, error) {
+	parts, err := splitBucketsPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bp := &ParsedBucketsPath{Elements: make([]BucketsPathElement, 0, len(parts))}
+	for i, part := range parts {
+		last := i == len(parts)-1
+
+		name, key, keyed, err := parseBucketsPathKey(part)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %s", ErrInvalidBucketsPath, path, err.Error())
+		}
+
+		if last && keyed {
+			// the key of the last element is the property: `the_percentiles[99.9]`
+			bp.Property, bp.bracketProperty = key, true
+			key, keyed = "", false
+		} else if last {
+			if dot := strings.LastIndex(name, "."); dot >= 0 {
+				if dot == 0 || dot == len(name)-1 {
+					return nil, fmt.Errorf("%w: %q: invalid property", ErrInvalidBucketsPath, path)
+				}
+				name, bp.Property = name[:dot], name[dot+1:]
+			}
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("%w: %q: empty aggregation name", ErrInvalidBucketsPath, path)
+		}
+		bp.Elements = append(bp.Elements, BucketsPathElement{Name: name, Key: key, Keyed: keyed})
+	}
+
+	return bp, nil
+}
+
+// splitBucketsPath splits the path by `>` which is not in brackets
+func splitBucketsPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrInvalidBucketsPath)
+	}
+
+	parts := make([]string, 0)
+	depth, start := 0, 0
+	for i, r := range path {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("%w: %q: unexpected ]", ErrInvalidBucketsPath, path)
+			}
+		case '>':
+			if depth == 0 {
+				parts = append(parts, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: %q: unclosed [", ErrInvalidBucketsPath, path)
+	}
+
+	return append(parts, path[start:]), nil
+}
+
+// parseBucketsPathKey splits `name[key]` element
+func parseBucketsPathKey(element string) (name, key string, keyed bool, err error) {
+	open := strings.Index(element, "[")
+	if open < 0 {
+		if strings.Contains(element, "]") {
+			err = fmt.Errorf("unexpected ] in %q", element)
+		}
+		return element, "", false, err
+	}
+
+	if !strings.HasSuffix(element, "]") || strings.Count(element, "[") != 1 {
+		return "", "", false, fmt.Errorf("invalid key in %q", element)
+	}
+
+	return element[:open], element[open+1 : len(element)-1], true, nil
+}
+
+// String returns the buckets_path syntax
+func (p *ParsedBucketsPath) String() string {
+	b := strings.Builder{}
+	for i, element := range p.Elements {
+		if i > 0 {
+			b.WriteByte('>')
+		}
+		b.WriteString(element.Name)
+		if element.Keyed {
+			b.WriteString("[" + element.Key + "]")
+		}
+	}
+
+	if p.Property != "" {
+		if p.bracketProperty {
+			b.WriteString("[" + p.Property + "]")
+		} else {
+			b.WriteString("." + p.Property)
+		}
+	}
+
+	return b.String()
+}
+
+// IsSpecial returns true for `_count` and `_key` paths which don't refer an aggregation
+func (p *ParsedBucketsPath) IsSpecial() bool {
+	return len(p.Elements) == 1 && p.Property == "" &&
+		(p.Elements[0].Name == BucketsPathCount || p.Elements[0].Name == BucketsPathKey)
+}
+
+// aggregationSelector is a root of aggregations: Aggregation or *Aggregations
+type aggregationSelector interface {
+	Select(path ...string) Aggregation
+}
+
+// ResolveBucketsPath resolves buckets_path of the pipeline aggregation located in agg by pipelinePath.
+// It checks that the target exists, it's a metric and a valid property is given for multi-value metric.
+// The target aggregation is returned (nil for `_count` and `_key`; the bucket aggregation for `>_count` and `._bucket_count`).
+// The error is *PathError with the path of pipeline
+func ResolveBucketsPath(agg Aggregation, pipelinePath []string, bucketsPath string) (Aggregation, error) {
+	return resolveBucketsPath(agg, pipelinePath, bucketsPath)
+}
+
+// ResolveBucketsPath resolves buckets_path of the pipeline aggregation located in the map by pipelinePath. See ResolveBucketsPath()
+func (a *Aggregations) ResolveBucketsPath(pipelinePath []string, bucketsPath string) (Aggregation, error) {
+	return resolveBucketsPath(a, pipelinePath, bucketsPath)
+}
+
+func resolveBucketsPath(root aggregationSelector, pipelinePath []string, bucketsPath string) (Aggregation, error) {
+	if len(pipelinePath) == 0 {
+		return nil, &PathError{Op: "ResolveBucketsPath", Err: ErrNoPath}
+	}
+
+	bp, err := ParseBucketsPath(bucketsPath)
+	if err != nil {
+		return nil, &PathError{Op: "ResolveBucketsPath", Path: pipelinePath, Node: root.Select(pipelinePath...), Err: err}
+	}
+	if bp.IsSpecial() {
+		return nil, nil
+	}
+
+	fail := func(format string, args ...interface{}) (Aggregation, error) {
+		return nil, &PathError{
+			Op:   "ResolveBucketsPath",
+			Path: pipelinePath,
+			Node: root.Select(pipelinePath...),
+			Err:  fmt.Errorf("%w: %q: %s", ErrInvalidBucketsPath, bucketsPath, fmt.Sprintf(format, args...)),
+		}
+	}
+
+	// buckets_path is relative to the parent of pipeline
+	path := joinPath(pipelinePath[:len(pipelinePath)-1])
+	var target Aggregation
+	for i, element := range bp.Elements {
+		last := i == len(bp.Elements)-1
+
+		if last && element.Name == BucketsPathCount && target != nil && bp.Property == "" {
+			// doc count of the bucket: `sale_type['hat']>_count`
+			return target, nil
+		}
+
+		path = joinPath(path, element.Name)
+		target = root.Select(path...)
+		if target == nil {
+			return fail("%s is not found", strings.Join(path, ">"))
+		}
+
+		if i == 0 && isSiblingPipelineAggregation(root.Select(pipelinePath...)) && !isMultiBucketAggregation(target) {
+			return fail("%s is not a multi-bucket aggregation", strings.Join(path, ">"))
+		}
+		if element.Keyed && !isMultiBucketAggregation(target) {
+			return fail("%s is not a multi-bucket aggregation", strings.Join(path, ">"))
+		}
+		if !last && !isBucketAggregation(target) {
+			return fail("%s is not a bucket aggregation", strings.Join(path, ">"))
+		}
+	}
+
+	if bp.Property == BucketsPathBucketCount {
+		if !isMultiBucketAggregation(target) {
+			return fail("%s is not a multi-bucket aggregation", strings.Join(path, ">"))
+		}
+		return target, nil
+	}
+
+	if isBucketAggregation(target) {
+		return fail("%s is not a metric aggregation", strings.Join(path, ">"))
+	}
+
+	if err := checkMetricProperty(target, bp.Property); err != nil {
+		return fail("%s: %s", strings.Join(path, ">"), err.Error())
+	}
+
+	return target, nil
+}
+
+var (
+	statsProperties         = []string{"count", "min", "max", "avg", "sum"}
+	extendedStatsProperties = append([]string{
+		"sum_of_squares", "variance", "variance_population", "variance_sampling",
+		"std_deviation", "std_deviation_population", "std_deviation_sampling",
+		"std_upper", "std_lower", "std_upper_population", "std_lower_population", "std_upper_sampling", "std_lower_sampling",
+	}, statsProperties...)
+	defaultPercents = []float64{1, 5, 25, 50, 75, 95, 99}
+)
+
+// checkMetricProperty checks the property of metric (or value producing pipeline) referred by buckets_path
+func checkMetricProperty(agg Aggregation, property string) error {
+	switch a := agg.(type) {
+	case *StatsAggregation, *StatsBucketAggregation:
+		return checkProperty(property, statsProperties)
+	case *ExtendedStatsAggregation:
+		return checkProperty(property, extendedStatsProperties)
+	case *PercentilesAggregation:
+		return checkPercentProperty(property, a.percentiles)
+	case *PercentileRanksAggregation:
+		return checkPercentProperty(property, a.values)
+	case *PercentilesBucketAggregation:
+		return checkPercentProperty(property, a.percents)
+	case *GeoBoundsAggregation, *GeoCentroidAggregation, *MatrixStatsAggregation,
+		*BucketSelectorAggregation, *BucketSortAggregation:
+		return fmt.Errorf("the aggregation has no numeric value")
+	}
+
+	// single-value metric
+	if property != "" && property != "value" {
+		return fmt.Errorf("single-value metric has no property %q", property)
+	}
+	return nil
+}
+
+func checkProperty(property string, valid []string) error {
+	if property == "" {
+		return fmt.Errorf("multi-value metric requires a property, one of %s", strings.Join(valid, ", "))
+	}
+	if indexOfName(valid, property) < 0 {
+		return fmt.Errorf("unknown property %q, expected one of %s", property, strings.Join(valid, ", "))
+	}
+	return nil
+}
+
+func checkPercentProperty(property string, percents []float64) error {
+	if property == "" {
+		return fmt.Errorf("percentiles require a percent property")
+	}
+	if len(percents) == 0 {
+		percents = defaultPercents
+	}
+
+	percent, err := strconv.ParseFloat(property, 64)
+	if err != nil {
+		return fmt.Errorf("invalid percent property %q", property)
+	}
+	for _, p := range percents {
+		if p == percent {
+			return nil
+		}
+	}
+	return fmt.Errorf("percent %q is not requested", property)
+}
+
+// bucketsPathsOf returns all the buckets_path values of pipeline aggregation.
+// The fields of bucket_sort sorters are buckets paths as well
+func bucketsPathsOf(agg Aggregation) []string {
+	switch a := agg.(type) {
+	case *AvgBucketAggregation:
+		return a.bucketsPaths
+	case *SumBucketAggregation:
+		return a.bucketsPaths
+	case *MinBucketAggregation:
+		return a.bucketsPaths
+	case *MaxBucketAggregation:
+		return a.bucketsPaths
+	case *StatsBucketAggregation:
+		return a.bucketsPaths
+	case *PercentilesBucketAggregation:
+		return a.bucketsPaths
+	case *DerivativeAggregation:
+		return a.bucketsPaths
+	case *CumulativeSumAggregation:
+		return a.bucketsPaths
+	case *SerialDiffAggregation:
+		return a.bucketsPaths
+	case *MovAvgAggregation:
+		return a.bucketsPaths
+	case *BucketScriptAggregation:
+		return mapBucketsPaths(a.bucketsPathsMap)
+	case *BucketSelectorAggregation:
+		return mapBucketsPaths(a.bucketsPathsMap)
+	case *BucketSortAggregation:
+		paths := make([]string, 0, len(a.sorters))
+		for _, sorter := range a.sorters {
+			switch s := sorter.(type) {
+			case elastic.SortInfo:
+				paths = append(paths, s.Field)
+			case *elastic.SortInfo:
+				paths = append(paths, s.Field)
+			}
+		}
+		return paths
+	}
+	return nil
+}
+
+// mapBucketsPaths returns the paths of the map in order of their variables
+func mapBucketsPaths(m map[string]string) []string {
+	paths := make([]string, 0, len(m))
+	for _, name := range sortedStrings(m) {
+		paths = append(paths, m[name])
+	}
+	return paths
+}
+
+// setBucketsPaths replaces the buckets_path values of pipeline aggregation.
+// The paths are given in the same order as bucketsPathsOf() returns them
+func setBucketsPaths(agg Aggregation, paths []string) {
+	switch a := agg.(type) {
+	case *AvgBucketAggregation:
+		a.bucketsPaths = paths
+	case *SumBucketAggregation:
+		a.bucketsPaths = paths
+	case *MinBucketAggregation:
+		a.bucketsPaths = paths
+	case *MaxBucketAggregation:
+		a.bucketsPaths = paths
+	case *StatsBucketAggregation:
+		a.bucketsPaths = paths
+	case *PercentilesBucketAggregation:
+		a.bucketsPaths = paths
+	case *DerivativeAggregation:
+		a.bucketsPaths = paths
+	case *CumulativeSumAggregation:
+		a.bucketsPaths = paths
+	case *SerialDiffAggregation:
+		a.bucketsPaths = paths
+	case *MovAvgAggregation:
+		a.bucketsPaths = paths
+	case *BucketScriptAggregation:
+		a.bucketsPathsMap = setMapBucketsPaths(a.bucketsPathsMap, paths)
+	case *BucketSelectorAggregation:
+		a.bucketsPathsMap = setMapBucketsPaths(a.bucketsPathsMap, paths)
+	case *BucketSortAggregation:
+		sorters := make([]elastic.Sorter, len(a.sorters))
+		i := 0
+		for j, sorter := range a.sorters {
+			switch s := sorter.(type) {
+			case elastic.SortInfo:
+				s.Field = paths[i]
+				sorters[j] = s
+				i++
+			case *elastic.SortInfo:
+				info := *s
+				info.Field = paths[i]
+				sorters[j] = &info
+				i++
+			default:
+				sorters[j] = sorter
+			}
+		}
+		a.sorters = sorters
+	}
+}
+
+func setMapBucketsPaths(m map[string]string, paths []string) map[string]string {
+	result := make(map[string]string, len(m))
+	for i, name := range sortedStrings(m) {
+		result[name] = paths[i]
+	}
+	return result
+}
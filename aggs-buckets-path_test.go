@@ -0,0 +1,113 @@
+package aggretastic_test
+
+import (
+	"errors"
+
+	"github.com/aahainc/aggretastic"
+	"github.com/olivere/elastic/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BucketsPath", func() {
+
+	It("should parse buckets_path syntax", func() {
+		bp, err := aggretastic.ParseBucketsPath("sale_type['hat']>sales>the_stats.avg")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(bp.Elements).To(Equal([]aggretastic.BucketsPathElement{
+			{Name: "sale_type", Key: "'hat'", Keyed: true},
+			{Name: "sales"},
+			{Name: "the_stats"},
+		}))
+		Expect(bp.Property).To(Equal("avg"))
+
+		bp, err = aggretastic.ParseBucketsPath("by_day>load[99.9]")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(bp.Elements).To(HaveLen(2))
+		Expect(bp.Property).To(Equal("99.9"))
+
+		for _, path := range []string{"sale_type['hat']>sales>the_stats.avg", "by_day>load[99.9]", "terms._bucket_count", "_count"} {
+			bp, err := aggretastic.ParseBucketsPath(path)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(bp.String()).To(Equal(path))
+		}
+
+		for _, path := range []string{"", "a>>b", "a[b", "a]b", "a.", ".a", "a>b[c]d"} {
+			_, err := aggretastic.ParseBucketsPath(path)
+			Expect(errors.Is(err, aggretastic.ErrInvalidBucketsPath)).To(BeTrue(), path)
+		}
+	})
+
+	Context("resolving", func() {
+
+		var aggs aggretastic.Aggregations
+
+		BeforeEach(func() {
+			byCountry := aggretastic.NewTermsAggregation().Field("country")
+			byCountry.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+			byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "revenue")
+			byCountry.Inject(aggretastic.NewStatsAggregation().Field("price"), "by_day", "price")
+			byCountry.Inject(aggretastic.NewPercentilesAggregation().Field("latency").Percentiles(50, 99.9), "by_day", "latency")
+			byCountry.Inject(aggretastic.NewFilterAggregation().Filter(elastic.NewTermQuery("paid", true)), "by_day", "paid")
+			byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "paid", "revenue")
+			aggs = aggretastic.Aggregations{"by_country": byCountry}
+		})
+
+		resolve := func(pipelinePath []string, bucketsPath string) error {
+			_, err := aggs.ResolveBucketsPath(pipelinePath, bucketsPath)
+			return err
+		}
+
+		It("should resolve valid paths", func() {
+			inDay := []string{"by_country", "by_day", "x"}
+			Expect(resolve(inDay, "revenue")).To(Succeed())
+			Expect(resolve(inDay, "revenue.value")).To(Succeed())
+			Expect(resolve(inDay, "price.avg")).To(Succeed())
+			Expect(resolve(inDay, "latency[99.9]")).To(Succeed())
+			Expect(resolve(inDay, "latency.50")).To(Succeed())
+			Expect(resolve(inDay, "paid>revenue")).To(Succeed())
+			Expect(resolve(inDay, "paid>_count")).To(Succeed())
+			Expect(resolve(inDay, "_count")).To(Succeed())
+			Expect(resolve(inDay, "_key")).To(Succeed())
+
+			inCountry := []string{"by_country", "x"}
+			Expect(resolve(inCountry, "by_day>price.max")).To(Succeed())
+			Expect(resolve(inCountry, "by_day._bucket_count")).To(Succeed())
+
+			target, err := aggs.ResolveBucketsPath(inCountry, "by_day>paid>revenue")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(target).To(BeIdenticalTo(aggs.Select("by_country", "by_day", "paid", "revenue")))
+		})
+
+		It("should report invalid paths", func() {
+			inDay := []string{"by_country", "by_day", "x"}
+			for _, path := range []string{"revenu", "price", "price.median", "latency.95", "revenue.avg", "paid", "revenue>x", "paid['a']>revenue", "paid._bucket_count"} {
+				err := resolve(inDay, path)
+				Expect(errors.Is(err, aggretastic.ErrInvalidBucketsPath)).To(BeTrue(), path)
+				Expect(err.Error()).To(ContainSubstring("by_country>by_day>x"))
+
+				var pathErr *aggretastic.PathError
+				Expect(errors.As(err, &pathErr)).To(BeTrue(), path)
+				Expect(pathErr.Path).To(Equal(inDay))
+			}
+		})
+
+		It("should validate pipelines of the tree", func() {
+			aggs.Inject(aggretastic.NewDerivativeAggregation().BucketsPath("price.avg"), "by_country", "by_day", "growth")
+			aggs.Inject(aggretastic.NewMaxBucketAggregation().BucketsPath("by_day>revenue"), "by_country", "best_day")
+			aggs.Inject(aggretastic.NewBucketSortAggregation().Sort("revenue", false), "by_country", "by_day", "top")
+			Expect(aggs.Validate()).To(Succeed())
+
+			aggs.Inject(aggretastic.NewMaxBucketAggregation().BucketsPath("revenue"), "by_country", "by_day", "best")
+			err := aggs.Validate()
+			Expect(errors.Is(err, aggretastic.ErrInvalidBucketsPath)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("by_country>by_day>best"))
+
+			var pathErr *aggretastic.PathError
+			Expect(errors.As(err, &pathErr)).To(BeTrue())
+			Expect(pathErr.Op).To(Equal("Validate"))
+			Expect(pathErr.Path).To(Equal([]string{"by_country", "by_day", "best"}))
+			Expect(pathErr.Node).To(BeIdenticalTo(aggs.Select("by_country", "by_day", "best")))
+		})
+	})
+})
@@ -0,0 +1,265 @@
+package aggretastic
+
+import "github.com/olivere/elastic/v7"
+
+// Clone returns a deep copy of the map of aggregations
+func (a *Aggregations) Clone() Aggregations {
+	if a == nil || *a == nil {
+		return nil
+	}
+
+	result := make(Aggregations, len(*a))
+	for name, agg := range *a {
+		result[name] = agg.Clone()
+	}
+
+	return result
+}
+
+// cloneFor returns a deep copy of the tree attached to the new root
+func (a *tree) cloneFor(root elastic.Aggregation) *tree {
+	c := nilAggregationTree(root)
+	for _, name := range a.subAggregations.Names() {
+		subAgg, _ := a.subAggregations.Get(name)
+		c.subAggregations.Set(name, subAgg.Clone())
+	}
+
+	return c
+}
+
+// shareFor returns a copy of the tree attached to the new root which shares the subAggregations with the original tree
+func (a *tree) shareFor(root elastic.Aggregation) *tree {
+	c := nilAggregationTree(root)
+	for _, name := range a.subAggregations.Names() {
+		subAgg, _ := a.subAggregations.Get(name)
+		c.subAggregations.Set(name, subAgg)
+	}
+
+	return c
+}
+
+// treeCloner copies the tree of aggregation attached to the new root: cloneFor or shareFor
+type treeCloner func(root elastic.Aggregation) *tree
+
+// treeSharer is implemented by bucket aggregations: they can be copied sharing their subAggregations
+type treeSharer interface {
+	cloneWith(cloneTree treeCloner) Aggregation
+	shareFor(root elastic.Aggregation) *tree
+}
+
+// cloneFor returns a copy of notInjectable attached to the new root
+func (a *notInjectable) cloneFor(root elastic.Aggregation) *notInjectable {
+	return newNotInjectable(root)
+}
+
+//
+// helpers
+//
+// Scalar pointers (*int, *bool etc.) are not copied:
+// builders always replace them and never change the value they point to.
+// Queries and sorters are shared as well, they are never modified by aggregations.
+//
+
+// cloneScript returns a copy of the script with the deep copy of its params
+func cloneScript(s *elastic.Script) *elastic.Script {
+	if s == nil {
+		return nil
+	}
+
+	c := *s
+	if src, err := s.Source(); err == nil {
+		if m, ok := src.(map[string]interface{}); ok {
+			if params, ok := m["params"].(map[string]interface{}); ok {
+				c.Params(cloneMap(params))
+			}
+		}
+	}
+
+	return &c
+}
+
+// cloneValue returns a deep copy of JSON-like value: maps and slices are copied recursively
+func cloneValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return cloneMap(value)
+	case []interface{}:
+		return cloneSlice(value)
+	case map[string]string:
+		return cloneStringsMap(value)
+	case []string:
+		return cloneStrings(value)
+	case []float64:
+		return cloneFloat64s(value)
+	default:
+		return v
+	}
+}
+
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = cloneValue(v)
+	}
+	return c
+}
+
+func cloneSlice(s []interface{}) []interface{} {
+	if s == nil {
+		return nil
+	}
+
+	c := make([]interface{}, len(s))
+	for i, v := range s {
+		c[i] = cloneValue(v)
+	}
+	return c
+}
+
+func cloneStringsMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+
+	c := make([]string, len(s))
+	copy(c, s)
+	return c
+}
+
+func cloneFloat64s(s []float64) []float64 {
+	if s == nil {
+		return nil
+	}
+
+	c := make([]float64, len(s))
+	copy(c, s)
+	return c
+}
+
+func cloneQueriesMap(m map[string]elastic.Query) map[string]elastic.Query {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]elastic.Query, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func cloneTermsOrder(order []TermsOrder) []TermsOrder {
+	if order == nil {
+		return nil
+	}
+
+	c := make([]TermsOrder, len(order))
+	copy(c, order)
+	return c
+}
+
+func cloneIncludeExclude(ie *TermsAggregationIncludeExclude) *TermsAggregationIncludeExclude {
+	if ie == nil {
+		return nil
+	}
+
+	c := *ie
+	c.IncludeValues = cloneSlice(ie.IncludeValues)
+	c.ExcludeValues = cloneSlice(ie.ExcludeValues)
+	return &c
+}
+
+func cloneSignificanceHeuristic(sh SignificanceHeuristic) SignificanceHeuristic {
+	switch h := sh.(type) {
+	case *ChiSquareSignificanceHeuristic:
+		c := *h
+		return &c
+	case *GNDSignificanceHeuristic:
+		c := *h
+		return &c
+	case *MutualInformationSignificanceHeuristic:
+		c := *h
+		return &c
+	case *ScriptSignificanceHeuristic:
+		return &ScriptSignificanceHeuristic{script: cloneScript(h.script)}
+	default:
+		// stateless or unknown heuristic
+		return sh
+	}
+}
+
+func cloneMovAvgModel(model MovAvgModel) MovAvgModel {
+	switch m := model.(type) {
+	case *EWMAMovAvgModel:
+		c := *m
+		return &c
+	case *HoltLinearMovAvgModel:
+		c := *m
+		return &c
+	case *HoltWintersMovAvgModel:
+		c := *m
+		return &c
+	default:
+		// stateless or unknown model
+		return model
+	}
+}
+
+func cloneCompositeValuesSources(sources []CompositeAggregationValuesSource) []CompositeAggregationValuesSource {
+	if sources == nil {
+		return nil
+	}
+
+	c := make([]CompositeAggregationValuesSource, len(sources))
+	for i, source := range sources {
+		switch s := source.(type) {
+		case *CompositeAggregationTermsValuesSource:
+			cs := *s
+			cs.script = cloneScript(s.script)
+			cs.missing = cloneValue(s.missing)
+			c[i] = &cs
+		case *CompositeAggregationHistogramValuesSource:
+			cs := *s
+			cs.script = cloneScript(s.script)
+			cs.missing = cloneValue(s.missing)
+			c[i] = &cs
+		case *CompositeAggregationDateHistogramValuesSource:
+			cs := *s
+			cs.script = cloneScript(s.script)
+			cs.missing = cloneValue(s.missing)
+			cs.interval = cloneValue(s.interval)
+			c[i] = &cs
+		default:
+			// unknown source can't be copied
+			c[i] = source
+		}
+	}
+	return c
+}
+
+func cloneFieldConfig(config *MultiValuesSourceFieldConfig) *MultiValuesSourceFieldConfig {
+	if config == nil {
+		return nil
+	}
+
+	c := *config
+	c.Missing = cloneValue(config.Missing)
+	c.Script = cloneScript(config.Script)
+	return &c
+}
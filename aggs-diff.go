@@ -0,0 +1,178 @@
+package aggretastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeType is the kind of change of an aggregation
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+// Change is a difference between two aggregation trees
+type Change struct {
+	Type ChangeType
+
+	// Path of the added, removed or modified aggregation. It's empty for the root
+	Path []string
+
+	// Fields are the changed fields of modified aggregation
+	Fields []FieldChange
+}
+
+// FieldChange is a changed field of aggregation definition
+type FieldChange struct {
+	// Field is the dotted path of the field in aggregation source e.g. "terms.size"
+	Field string
+
+	// From and To are the values before and after the change; nil is a missing value
+	From interface{}
+	To   interface{}
+}
+
+// String returns a readable change e.g. "~ by_country: terms.size 10→50"
+func (c Change) String() string {
+	path := strings.Join(c.Path, ">")
+	if path == "" {
+		path = "(root)"
+	}
+
+	switch c.Type {
+	case ChangeAdded:
+		return "+ " + path
+	case ChangeRemoved:
+		return "- " + path
+	}
+
+	fields := make([]string, 0, len(c.Fields))
+	for _, field := range c.Fields {
+		fields = append(fields, field.String())
+	}
+	return "~ " + path + ": " + strings.Join(fields, ", ")
+}
+
+// String returns a readable field change e.g. "date_histogram.calendar_interval 1d→1h"
+func (f FieldChange) String() string {
+	return f.Field + " " + formatDiffValue(f.From) + "→" + formatDiffValue(f.To)
+}
+
+func formatDiffValue(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "<none>"
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// Diff returns the changes which turn the tree `a` into the tree `b`.
+// Added and removed aggregations are reported without their subAggregations.
+// Modified aggregations are reported with the changed fields of their definitions (subAggregations are compared separately)
+func Diff(a, b Aggregation) []Change {
+	changes := make([]Change, 0)
+	diffNode(&changes, []string{}, a, b)
+	return changes
+}
+
+// Diff returns the changes which turn the map of aggregations into the map `b`. See Diff()
+func (a *Aggregations) Diff(b Aggregations) []Change {
+	changes := make([]Change, 0)
+
+	var left Aggregations
+	if a != nil {
+		left = *a
+	}
+
+	for _, name := range sortedNames(left) {
+		if right, ok := b[name]; ok {
+			diffNode(&changes, []string{name}, left[name], right)
+		} else {
+			changes = append(changes, Change{Type: ChangeRemoved, Path: []string{name}})
+		}
+	}
+	for _, name := range sortedNames(b) {
+		if _, ok := left[name]; !ok {
+			changes = append(changes, Change{Type: ChangeAdded, Path: []string{name}})
+		}
+	}
+
+	return changes
+}
+
+func diffNode(changes *[]Change, path []string, a, b Aggregation) {
+	fields := make([]FieldChange, 0)
+	diffValues(&fields, "", definitionValue(a), definitionValue(b))
+	if len(fields) > 0 {
+		*changes = append(*changes, Change{Type: ChangeModified, Path: path, Fields: fields})
+	}
+
+	aSubs, bSubs := a.GetAllSubs(), b.GetAllSubs()
+	for _, name := range a.GetSubNames() {
+		if right, ok := bSubs[name]; ok {
+			diffNode(changes, joinPath(path, name), aSubs[name], right)
+		} else {
+			*changes = append(*changes, Change{Type: ChangeRemoved, Path: joinPath(path, name)})
+		}
+	}
+	for _, name := range b.GetSubNames() {
+		if _, ok := aSubs[name]; !ok {
+			*changes = append(*changes, Change{Type: ChangeAdded, Path: joinPath(path, name)})
+		}
+	}
+}
+
+// definitionValue returns the definition of aggregation as a generic JSON value
+func definitionValue(agg Aggregation) interface{} {
+	def, err := definitionOf(agg)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(def, &v); err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	return v
+}
+
+// diffValues compares JSON values: objects are compared by their keys, other values (incl. arrays) as a whole
+func diffValues(fields *[]FieldChange, field string, a, b interface{}) {
+	aMap, aOk := a.(map[string]interface{})
+	bMap, bOk := b.(map[string]interface{})
+	if !aOk || !bOk {
+		if !reflect.DeepEqual(a, b) {
+			*fields = append(*fields, FieldChange{Field: field, From: a, To: b})
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(aMap)+len(bMap))
+	for k := range aMap {
+		keys = append(keys, k)
+	}
+	for k := range bMap {
+		if _, ok := aMap[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		subField := k
+		if field != "" {
+			subField = field + "." + k
+		}
+		diffValues(fields, subField, aMap[k], bMap[k])
+	}
+}
@@ -0,0 +1,58 @@
+package aggretastic_test
+
+import (
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff", func() {
+
+	It("should report added, removed and modified aggregations", func() {
+		before := aggretastic.NewTermsAggregation().Field("country").Size(10)
+		before.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at").CalendarInterval("1d"), "by_day")
+		before.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "revenue")
+		before.Inject(aggretastic.NewAvgAggregation().Field("price"), "avg_price")
+
+		after := before.Clone().(*aggretastic.TermsAggregation).Size(50)
+		after.Select("by_day").(*aggretastic.DateHistogramAggregation).CalendarInterval("1h")
+		after.Pop("avg_price")
+		after.Inject(aggretastic.NewCardinalityAggregation().Field("customer"), "by_day", "customers")
+
+		changes := aggretastic.Diff(before, after)
+		Expect(changes).To(Equal([]aggretastic.Change{
+			{Type: aggretastic.ChangeModified, Path: []string{}, Fields: []aggretastic.FieldChange{{Field: "terms.size", From: 10.0, To: 50.0}}},
+			{Type: aggretastic.ChangeModified, Path: []string{"by_day"}, Fields: []aggretastic.FieldChange{{Field: "date_histogram.calendar_interval", From: "1d", To: "1h"}}},
+			{Type: aggretastic.ChangeAdded, Path: []string{"by_day", "customers"}},
+			{Type: aggretastic.ChangeRemoved, Path: []string{"avg_price"}},
+		}))
+
+		Expect(changes[0].String()).To(Equal("~ (root): terms.size 10→50"))
+		Expect(changes[1].String()).To(Equal("~ by_day: date_histogram.calendar_interval 1d→1h"))
+		Expect(changes[2].String()).To(Equal("+ by_day>customers"))
+		Expect(changes[3].String()).To(Equal("- avg_price"))
+
+		Expect(aggretastic.Diff(before, before.Clone())).To(BeEmpty())
+	})
+
+	It("should diff the maps of aggregations", func() {
+		before := aggretastic.Aggregations{
+			"revenue": aggretastic.NewSumAggregation().Field("price"),
+			"total":   aggretastic.NewValueCountAggregation().Field("id"),
+		}
+		after := aggretastic.Aggregations{
+			"revenue":   aggretastic.NewAvgAggregation().Field("price"),
+			"customers": aggretastic.NewCardinalityAggregation().Field("customer"),
+		}
+
+		changes := before.Diff(after)
+		Expect(changes).To(HaveLen(3))
+		Expect(changes[0].String()).To(Equal(`~ revenue: avg <none>→{"field":"price"}, sum {"field":"price"}→<none>`))
+		Expect(changes[0].Fields).To(Equal([]aggretastic.FieldChange{
+			{Field: "avg", From: nil, To: map[string]interface{}{"field": "price"}},
+			{Field: "sum", From: map[string]interface{}{"field": "price"}, To: nil},
+		}))
+		Expect(changes[1].String()).To(Equal("- total"))
+		Expect(changes[2].String()).To(Equal("+ customers"))
+	})
+})
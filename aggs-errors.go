@@ -0,0 +1,61 @@
+package aggretastic
+
+import "fmt"
+
+// PathError records a failed tree operation with the place where it failed.
+// It wraps the sentinel errors (ErrNoPath, ErrPathNotSelectable etc.) so errors.Is() works with them
+type PathError struct {
+	// Op is the operation e.g. "Inject"
+	Op string
+
+	// Path is the prefix of the operation's path which has failed: the path of the aggregation which rejected the operation
+	// or the first path which doesn't exist. It's empty when the root of operation has failed
+	Path []string
+
+	// Node is the aggregation which rejected the operation. It's nil when the path doesn't exist
+	Node Aggregation
+
+	Err error
+}
+
+func (e *PathError) Error() string {
+	path := FormatPath(e.Path)
+	if path == "" {
+		path = "(root)"
+	}
+
+	if e.Node != nil {
+		return fmt.Sprintf("%s %s (%T): %v", e.Op, path, e.Node, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Op, path, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// withPathPrefix reports the PathError of a subtree operation as the error of the operation `op` of its parent:
+// the path of error is prefixed with the path of subtree. Other errors are returned as is
+func withPathPrefix(op string, prefix []string, err error) error {
+	pathErr, ok := err.(*PathError)
+	if !ok {
+		return err
+	}
+
+	return &PathError{
+		Op:   op,
+		Path: joinPath(prefix, pathErr.Path...),
+		Node: pathErr.Node,
+		Err:  pathErr.Err,
+	}
+}
+
+// missingPath returns the shortest prefix of path which can't be selected
+func missingPath(root aggregationSelector, path []string) []string {
+	for i := range path {
+		if root.Select(path[:i+1]...) == nil {
+			return joinPath(path[:i+1])
+		}
+	}
+	return joinPath(path)
+}
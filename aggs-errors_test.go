@@ -0,0 +1,52 @@
+package aggretastic_test
+
+import (
+	"errors"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PathError", func() {
+
+	var aggs aggretastic.Aggregations
+
+	BeforeEach(func() {
+		byCountry := aggretastic.NewTermsAggregation().Field("country")
+		byCountry.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+		byCountry.Inject(aggretastic.NewTermsAggregation().Field("city"), "by_day", "by_city")
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "by_city", "revenue")
+		byCountry.Inject(aggretastic.NewDerivativeAggregation().BucketsPath("revenue"), "by_day", "by_city", "growth")
+		aggs = aggretastic.Aggregations{"by_country": byCountry}
+	})
+
+	It("should report the level which rejected the injection", func() {
+		_, err := aggs.Inject(aggretastic.NewAvgAggregation(), "by_country", "by_day", "by_city", "revenue", "avg")
+		Expect(errors.Is(err, aggretastic.ErrAggIsNotInjectable)).To(BeTrue())
+
+		var pathErr *aggretastic.PathError
+		Expect(errors.As(err, &pathErr)).To(BeTrue())
+		Expect(pathErr.Op).To(Equal("Inject"))
+		Expect(pathErr.Path).To(Equal([]string{"by_country", "by_day", "by_city", "revenue"}))
+		Expect(pathErr.Node).To(BeAssignableToTypeOf(&aggretastic.SumAggregation{}))
+		Expect(err.Error()).To(Equal("Inject by_country>by_day>by_city>revenue (*aggretastic.SumAggregation): agg is not injectable"))
+
+		_, err = aggs.InjectSafe(aggretastic.NewAvgAggregation(), "by_country", "by_day", "by_city", "growth", "avg")
+		Expect(err.Error()).To(Equal("InjectSafe by_country>by_day>by_city>growth (*aggretastic.DerivativeAggregation): agg is not injectable"))
+	})
+
+	It("should report the missing path", func() {
+		_, err := aggs.Select("by_country").Inject(aggretastic.NewAvgAggregation(), "by_day", "by_hour", "by_minute", "avg")
+		Expect(errors.Is(err, aggretastic.ErrPathNotSelectable)).To(BeTrue())
+		Expect(err.Error()).To(Equal("Inject by_day>by_hour: path is not selectable"))
+
+		_, err = aggs.InjectX(aggretastic.NewAvgAggregation())
+		Expect(errors.Is(err, aggretastic.ErrNoPath)).To(BeTrue())
+		Expect(err.Error()).To(Equal("InjectX (root): no path"))
+
+		err = aggs.Move([]string{"by_country", "by_week", "revenue"}, []string{"by_country", "revenue"})
+		Expect(errors.Is(err, aggretastic.ErrSubAggNotFound)).To(BeTrue())
+		Expect(err.Error()).To(Equal("Move by_country>by_week: subAgg is not found"))
+	})
+})
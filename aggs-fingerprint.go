@@ -0,0 +1,240 @@
+package aggretastic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// aggregationDefaults are the options which are equal to their Elasticsearch defaults.
+// They are removed from the canonical source so explicit defaults don't change the fingerprint
+var aggregationDefaults = map[string]map[string]interface{}{
+	"terms": {
+		"size":                      10.0,
+		"min_doc_count":             1.0,
+		"show_term_doc_count_error": false,
+		"order":                     []interface{}{map[string]interface{}{"_count": "desc"}},
+	},
+	"multi_terms":    {"size": 10.0, "min_doc_count": 1.0, "show_term_doc_count_error": false},
+	"date_histogram": {"min_doc_count": 0.0, "keyed": false},
+	"histogram":      {"min_doc_count": 0.0, "keyed": false},
+	"range":          {"keyed": false},
+	"date_range":     {"keyed": false},
+	"ip_range":       {"keyed": false},
+	"composite":      {"size": 10.0},
+	"geohash_grid":   {"precision": 5.0, "size": 10000.0},
+	"filters":        {"other_bucket": false},
+	"adjacency_matrix": {
+		"separator": "&",
+	},
+	"sampler":             {"shard_size": 100.0},
+	"diversified_sampler": {"shard_size": 100.0, "max_docs_per_value": 1.0},
+	"percentiles":         {"percents": []interface{}{1.0, 5.0, 25.0, 50.0, 75.0, 95.0, 99.0}, "keyed": true},
+	"percentile_ranks":    {"keyed": true},
+	"avg_bucket":          {"gap_policy": "skip"},
+	"sum_bucket":          {"gap_policy": "skip"},
+	"min_bucket":          {"gap_policy": "skip"},
+	"max_bucket":          {"gap_policy": "skip"},
+	"stats_bucket":        {"gap_policy": "skip"},
+	"percentiles_bucket":  {"gap_policy": "skip", "percents": []interface{}{1.0, 5.0, 25.0, 50.0, 75.0, 95.0, 99.0}},
+	"derivative":          {"gap_policy": "skip"},
+	"serial_diff":         {"gap_policy": "skip", "lag": 1.0},
+	"moving_avg":          {"gap_policy": "skip", "model": "simple", "window": 5.0},
+	"bucket_script":       {"gap_policy": "skip"},
+	"bucket_selector":     {"gap_policy": "skip"},
+	"bucket_sort":         {"gap_policy": "skip", "from": 0.0},
+}
+
+// unorderedOptions are the array options which are sets: their order doesn't change the result
+var unorderedOptions = map[string][]string{
+	"terms":              {"include", "exclude"},
+	"significant_terms":  {"include", "exclude"},
+	"significant_text":   {"include", "exclude", "source_fields"},
+	"percentiles":        {"percents"},
+	"percentile_ranks":   {"values"},
+	"percentiles_bucket": {"percents"},
+	"matrix_stats":       {"fields"},
+}
+
+// Fingerprint returns a stable hash of the semantic content of the aggregation tree.
+// The trees have the same fingerprint when they differ only by:
+//   - the order of keys and subAggregations;
+//   - explicitly set default options (e.g. terms.size 10);
+//   - the order of set-like options (named filters, include/exclude values, percents etc.);
+//   - the form of scripts ("x" and {"source": "x", "lang": "painless"}).
+func Fingerprint(agg Aggregation) (string, error) {
+	src, err := agg.Source()
+	if err != nil {
+		return "", err
+	}
+	return fingerprintOf(src, canonicalAggregation)
+}
+
+// Fingerprint returns a stable hash of the map of aggregations. See Fingerprint()
+func (a *Aggregations) Fingerprint() (string, error) {
+	src := make(map[string]interface{})
+	if a != nil {
+		for name, agg := range *a {
+			aggSrc, err := agg.Source()
+			if err != nil {
+				return "", err
+			}
+			src[name] = aggSrc
+		}
+	}
+	return fingerprintOf(src, canonicalAggregations)
+}
+
+func fingerprintOf(src interface{}, canonical func(interface{}) interface{}) (string, error) {
+	b, err := json.Marshal(src)
+	if err != nil {
+		return "", err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return "", err
+	}
+
+	// json.Marshal sorts the keys of maps
+	b, err = json.Marshal(canonical(v))
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalAggregations canonicalizes the object of named aggregations
+func canonicalAggregations(v interface{}) interface{} {
+	aggs, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+
+	result := make(map[string]interface{}, len(aggs))
+	for name, agg := range aggs {
+		result[name] = canonicalAggregation(agg)
+	}
+	return result
+}
+
+// canonicalAggregation canonicalizes the source of an aggregation
+func canonicalAggregation(v interface{}) interface{} {
+	node, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+
+	result := make(map[string]interface{}, len(node))
+	for key, value := range node {
+		switch key {
+		case "aggregations", "aggs":
+			if subAggs := canonicalAggregations(value); len(subAggs.(map[string]interface{})) > 0 {
+				result["aggregations"] = subAggs
+			}
+		case "meta":
+			result[key] = value
+		default:
+			result[key] = canonicalOptions(key, value)
+		}
+	}
+	return result
+}
+
+// canonicalOptions canonicalizes the options of aggregation of the type
+func canonicalOptions(typ string, v interface{}) interface{} {
+	opts, ok := canonicalScripts(v).(map[string]interface{})
+	if !ok {
+		return v
+	}
+
+	result := make(map[string]interface{}, len(opts))
+	for key, value := range opts {
+		if def, ok := aggregationDefaults[typ][key]; ok && (reflect.DeepEqual(def, value) || reflect.DeepEqual(def, canonicalSet(value))) {
+			continue
+		}
+		result[key] = value
+	}
+
+	for _, key := range unorderedOptions[typ] {
+		if value, ok := result[key]; ok {
+			result[key] = canonicalSet(value)
+		}
+	}
+
+	return result
+}
+
+// canonicalScripts turns every script into the object form without default language
+func canonicalScripts(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, sub := range value {
+			if k == "script" || k == "init_script" || k == "map_script" || k == "combine_script" || k == "reduce_script" {
+				result[k] = canonicalScript(sub)
+				continue
+			}
+			result[k] = canonicalScripts(sub)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, sub := range value {
+			result[i] = canonicalScripts(sub)
+		}
+		return result
+	}
+	return v
+}
+
+func canonicalScript(v interface{}) interface{} {
+	switch script := v.(type) {
+	case string:
+		return map[string]interface{}{"source": script}
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(script))
+		for k, value := range script {
+			switch {
+			case k == "inline":
+				result["source"] = value
+			case k == "lang" && value == "painless":
+			case k == "params" && reflect.DeepEqual(value, map[string]interface{}{}):
+			default:
+				result[k] = value
+			}
+		}
+		return result
+	}
+	return v
+}
+
+// canonicalSet sorts the array by the JSON of its items dropping duplicates
+func canonicalSet(v interface{}) interface{} {
+	items, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+
+	keys := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		b, _ := json.Marshal(item)
+		keys[string(b)] = item
+	}
+
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	sorted := make([]interface{}, len(names))
+	for i, key := range names {
+		sorted[i] = keys[key]
+	}
+	return sorted
+}
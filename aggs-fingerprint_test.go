@@ -0,0 +1,85 @@
+package aggretastic_test
+
+import (
+	"github.com/aahainc/aggretastic"
+	"github.com/olivere/elastic/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fingerprint", func() {
+
+	fingerprint := func(agg aggretastic.Aggregation) string {
+		f, err := aggretastic.Fingerprint(agg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f).To(HaveLen(64))
+		return f
+	}
+
+	It("should ignore the order of subAggregations and the explicit defaults", func() {
+		a := aggretastic.NewTermsAggregation().Field("country")
+		a.Inject(aggretastic.NewSumAggregation().Field("price"), "revenue")
+		a.Inject(aggretastic.NewAvgAggregation().Field("price"), "avg_price")
+
+		b := aggretastic.NewTermsAggregation().Field("country").Size(10).MinDocCount(1).OrderByCountDesc()
+		b.Inject(aggretastic.NewAvgAggregation().Field("price"), "avg_price")
+		b.Inject(aggretastic.NewSumAggregation().Field("price"), "revenue")
+
+		Expect(fingerprint(a)).To(Equal(fingerprint(b)))
+
+		b.Size(50)
+		Expect(fingerprint(a)).NotTo(Equal(fingerprint(b)))
+	})
+
+	It("should canonicalize order-insensitive collections", func() {
+		a := aggretastic.NewFiltersAggregation().
+			FilterWithName("paid", elastic.NewTermQuery("status", "paid")).
+			FilterWithName("refunded", elastic.NewTermQuery("status", "refunded"))
+		b := aggretastic.NewFiltersAggregation().
+			FilterWithName("refunded", elastic.NewTermQuery("status", "refunded")).
+			FilterWithName("paid", elastic.NewTermQuery("status", "paid"))
+		Expect(fingerprint(a)).To(Equal(fingerprint(b)))
+
+		// anonymous filters define the order of buckets
+		c := aggretastic.NewFiltersAggregation().
+			Filter(elastic.NewTermQuery("status", "paid")).
+			Filter(elastic.NewTermQuery("status", "refunded"))
+		d := aggretastic.NewFiltersAggregation().
+			Filter(elastic.NewTermQuery("status", "refunded")).
+			Filter(elastic.NewTermQuery("status", "paid"))
+		Expect(fingerprint(c)).NotTo(Equal(fingerprint(d)))
+
+		terms := aggretastic.NewTermsAggregation().Field("country").IncludeValues("FR", "DE")
+		Expect(fingerprint(terms)).To(Equal(fingerprint(aggretastic.NewTermsAggregation().Field("country").IncludeValues("DE", "FR"))))
+
+		percentiles := aggretastic.NewPercentilesAggregation().Field("price").Percentiles(99, 50)
+		Expect(fingerprint(percentiles)).To(Equal(fingerprint(aggretastic.NewPercentilesAggregation().Field("price").Percentiles(50, 99))))
+		Expect(fingerprint(aggretastic.NewPercentilesAggregation().Field("price").Percentiles(1, 5, 25, 50, 75, 95, 99))).
+			To(Equal(fingerprint(aggretastic.NewPercentilesAggregation().Field("price"))))
+	})
+
+	It("should normalize scripts", func() {
+		a := aggretastic.NewSumAggregation().Script(elastic.NewScript("doc.price.value * 2"))
+		b := aggretastic.NewSumAggregation().Script(elastic.NewScript("doc.price.value * 2").Lang("painless"))
+		Expect(fingerprint(a)).To(Equal(fingerprint(b)))
+	})
+
+	It("should fingerprint the maps of aggregations", func() {
+		a := aggretastic.Aggregations{
+			"revenue": aggretastic.NewSumAggregation().Field("price"),
+			"total":   aggretastic.NewValueCountAggregation().Field("id"),
+		}
+		b := a.Clone()
+
+		fa, err := a.Fingerprint()
+		Expect(err).NotTo(HaveOccurred())
+		fb, err := b.Fingerprint()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fa).To(Equal(fb))
+
+		b["total"] = aggretastic.NewValueCountAggregation().Field("order_id")
+		fb, err = b.Fingerprint()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fa).NotTo(Equal(fb))
+	})
+})
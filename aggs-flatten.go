@@ -0,0 +1,309 @@
+package aggretastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/olivere/elastic/v7"
+)
+
+// Table is the flat representation of aggregation results: one row per leaf bucket combination
+type Table struct {
+	// Columns are dimensions first, then values
+	Columns []Column
+
+	// Rows are aligned with the columns. The missing value is nil
+	Rows [][]interface{}
+}
+
+// Column describes a column of the Table
+type Column struct {
+	// Name of dimension column is the name of bucket aggregation (or the name of composite source).
+	// Name of value column is the path of metric from its closest dimension joined by ">"
+	// and followed by the metric property for multi-value metrics e.g. "revenue", "paid>revenue", "price.avg"
+	Name string
+
+	// Dimension is true for columns of bucket keys
+	Dimension bool
+}
+
+// Flatten turns the search result into a table.
+// Every multi-bucket aggregation (terms, date_histogram, range, filters, composite etc.) along the path becomes a dimension column
+// and every metric becomes a value column. Single bucket aggregations (filter, nested etc.) don't make dimensions,
+// their metrics are prefixed with their names.
+// The key of bucket is used as dimension value; the formatted key (key_as_string) is preferred when it's returned
+func (a *Aggregations) Flatten(res *elastic.SearchResult) (*Table, error) {
+	if a == nil || res == nil || res.Aggregations == nil {
+		return nil, ErrResultNotFound
+	}
+
+	f := &flattener{columns: make(map[string]*Column)}
+
+	children := make([]namedAggregation, 0, len(*a))
+	for _, name := range sortedNames(*a) {
+		children = append(children, namedAggregation{name: name, agg: (*a)[name]})
+	}
+
+	rows, err := f.scope(children, res.Aggregations, flatRow{})
+	if err != nil {
+		return nil, err
+	}
+
+	return f.table(rows), nil
+}
+
+type namedAggregation struct {
+	name string
+	agg  Aggregation
+}
+
+// subAggregationsOf returns the subAggregations of agg in their order
+func subAggregationsOf(agg Aggregation) []namedAggregation {
+	subs := agg.GetAllSubs()
+	children := make([]namedAggregation, 0, len(subs))
+	for _, name := range agg.GetSubNames() {
+		children = append(children, namedAggregation{name: name, agg: subs[name]})
+	}
+	return children
+}
+
+// flatRow is a row of values by column names
+type flatRow map[string]interface{}
+
+func (r flatRow) copy() flatRow {
+	c := make(flatRow, len(r))
+	for k, v := range r {
+		c[k] = v
+	}
+	return c
+}
+
+type flattener struct {
+	columns map[string]*Column
+	order   []string
+}
+
+// dimensionResult is a multi-bucket aggregation result to split the rows by
+type dimensionResult struct {
+	namedAggregation
+	raw json.RawMessage
+}
+
+// scope returns the leaf rows of the bucket: values of the bucket are copied to all the rows of its sub buckets
+func (f *flattener) scope(children []namedAggregation, aggs elastic.Aggregations, row flatRow) ([]flatRow, error) {
+	row = row.copy()
+
+	dimensions, err := f.collect(children, aggs, nil, row)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dimensions) == 0 {
+		return []flatRow{row}, nil
+	}
+
+	rows := make([]flatRow, 0)
+	for _, dimension := range dimensions {
+		buckets, err := decodeResultBuckets(dimension.name, dimension.raw)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, bucket := range buckets {
+			bucketRow := row.copy()
+			f.dimensions(bucketRow, dimension.agg, bucket.buckets[0])
+
+			subRows, err := f.scope(subAggregationsOf(dimension.agg), bucket.aggs, bucketRow)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, subRows...)
+		}
+	}
+
+	return rows, nil
+}
+
+// collect puts the values of metrics to the row and returns the multi-bucket results.
+// Single bucket aggregations are collected as a part of the current bucket
+func (f *flattener) collect(children []namedAggregation, aggs elastic.Aggregations, prefix []string, row flatRow) ([]dimensionResult, error) {
+	dimensions := make([]dimensionResult, 0)
+
+	for _, child := range children {
+		raw, ok := aggs[child.name]
+		if !ok {
+			// e.g. bucket_selector and bucket_sort have no results
+			continue
+		}
+
+		switch {
+		case isMultiBucketAggregation(child.agg):
+			dimensions = append(dimensions, dimensionResult{namedAggregation: child, raw: raw})
+
+		case isSingleBucketAggregation(child.agg):
+			var bucketAggs elastic.Aggregations
+			if err := json.Unmarshal(raw, &bucketAggs); err != nil {
+				return nil, err
+			}
+
+			subDimensions, err := f.collect(subAggregationsOf(child.agg), bucketAggs, joinPath(prefix, child.name), row)
+			if err != nil {
+				return nil, err
+			}
+			dimensions = append(dimensions, subDimensions...)
+
+		default:
+			if err := f.values(row, strings.Join(joinPath(prefix, child.name), ">"), raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return dimensions, nil
+}
+
+// dimensions puts the key of bucket to the row
+func (f *flattener) dimensions(row flatRow, agg Aggregation, bucket ResultBucket) {
+	var value interface{} = bucket.Key
+	if bucket.KeyAsString != nil {
+		value = *bucket.KeyAsString
+	}
+
+	if key, ok := bucket.Key.(map[string]interface{}); ok {
+		// composite key: a dimension per source
+		for _, name := range compositeKeyNames(agg, key) {
+			f.set(row, name, true, key[name])
+		}
+		return
+	}
+
+	f.set(row, bucket.Name, true, value)
+}
+
+// values puts the value(s) of metric to the row.
+// The properties of multi-value metrics are put to separate columns: "price.avg", "latency.99.0" etc.
+func (f *flattener) values(row flatRow, name string, raw json.RawMessage) error {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return nil
+	}
+
+	switch trimmed[0] {
+	case '{':
+		keys, values, err := decodeOrderedObject(trimmed)
+		if err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			switch {
+			case key == "meta" || key == "keys" || strings.HasSuffix(key, "_as_string"):
+				continue
+			case key == "value" || key == "values":
+				err = f.values(row, name, values[i])
+			default:
+				err = f.values(row, name+"."+key, values[i])
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+	case '[':
+		// percentiles with keyed=false: [{"key": 99.0, "value": 10}]
+		var items []struct {
+			Key   json.Number     `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			// not a list of keyed values
+			return nil
+		}
+		for _, item := range items {
+			if item.Key == "" {
+				continue
+			}
+			if err := f.values(row, name+"."+item.Key.String(), item.Value); err != nil {
+				return err
+			}
+		}
+
+	default:
+		var value interface{}
+		if err := json.Unmarshal(trimmed, &value); err != nil {
+			return err
+		}
+		f.set(row, name, false, value)
+	}
+
+	return nil
+}
+
+func (f *flattener) set(row flatRow, name string, dimension bool, value interface{}) {
+	if _, ok := f.columns[name]; !ok {
+		f.columns[name] = &Column{Name: name, Dimension: dimension}
+		f.order = append(f.order, name)
+	}
+	row[name] = value
+}
+
+func (f *flattener) table(rows []flatRow) *Table {
+	t := &Table{
+		Columns: make([]Column, 0, len(f.order)),
+		Rows:    make([][]interface{}, 0, len(rows)),
+	}
+
+	for _, name := range f.order {
+		if f.columns[name].Dimension {
+			t.Columns = append(t.Columns, *f.columns[name])
+		}
+	}
+	for _, name := range f.order {
+		if !f.columns[name].Dimension {
+			t.Columns = append(t.Columns, *f.columns[name])
+		}
+	}
+
+	for _, row := range rows {
+		values := make([]interface{}, len(t.Columns))
+		for i, column := range t.Columns {
+			values[i] = row[column.Name]
+		}
+		t.Rows = append(t.Rows, values)
+	}
+
+	return t
+}
+
+// compositeKeyNames returns the names of composite key in order of the composite sources
+func compositeKeyNames(agg Aggregation, key map[string]interface{}) []string {
+	names := make([]string, 0, len(key))
+	if composite, ok := agg.(*CompositeAggregation); ok {
+		for _, source := range composite.sources {
+			var name string
+			switch s := source.(type) {
+			case *CompositeAggregationTermsValuesSource:
+				name = s.name
+			case *CompositeAggregationHistogramValuesSource:
+				name = s.name
+			case *CompositeAggregationDateHistogramValuesSource:
+				name = s.name
+			}
+			if _, ok := key[name]; ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	rest := make([]string, 0)
+	for name := range key {
+		if indexOfName(names, name) < 0 {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(names, rest...)
+}
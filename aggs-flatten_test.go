@@ -0,0 +1,112 @@
+package aggretastic_test
+
+import (
+	"encoding/json"
+
+	"github.com/aahainc/aggretastic"
+	"github.com/olivere/elastic/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Flatten", func() {
+
+	It("should make a row per leaf bucket combination", func() {
+		const response = `{
+			"aggregations": {
+				"by_country": {
+					"buckets": [
+						{
+							"key": "de", "doc_count": 3,
+							"paid": {"doc_count": 2, "revenue": {"value": 20}},
+							"by_day": {"buckets": [
+								{"key": 1577836800000, "key_as_string": "2020-01-01", "doc_count": 2,
+									"price": {"count": 2, "min": 1, "max": 3, "avg": 2, "sum": 4},
+									"latency": {"values": {"50.0": 10, "99.0": 30}}},
+								{"key": 1577923200000, "key_as_string": "2020-01-02", "doc_count": 1,
+									"price": {"count": 1, "min": 5, "max": 5, "avg": 5, "sum": 5},
+									"latency": {"values": {"50.0": 15, "99.0": 15}}}
+							]}
+						},
+						{
+							"key": "fr", "doc_count": 1,
+							"paid": {"doc_count": 0, "revenue": {"value": 0}},
+							"by_day": {"buckets": [
+								{"key": 1577836800000, "key_as_string": "2020-01-01", "doc_count": 1,
+									"price": {"count": 1, "min": 7, "max": 7, "avg": 7, "sum": 7},
+									"latency": {"values": {"50.0": 20, "99.0": null}}}
+							]}
+						}
+					]
+				}
+			}
+		}`
+
+		byCountry := aggretastic.NewTermsAggregation().Field("country")
+		byCountry.Inject(aggretastic.NewFilterAggregation().Filter(elastic.NewTermQuery("paid", true)), "paid")
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "paid", "revenue")
+		byCountry.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+		byCountry.Inject(aggretastic.NewStatsAggregation().Field("price"), "by_day", "price")
+		byCountry.Inject(aggretastic.NewPercentilesAggregation().Field("latency"), "by_day", "latency")
+		aggs := aggretastic.Aggregations{"by_country": byCountry}
+
+		res := &elastic.SearchResult{}
+		Expect(json.Unmarshal([]byte(response), res)).To(Succeed())
+
+		table, err := aggs.Flatten(res)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		names := make([]string, 0)
+		for _, column := range table.Columns {
+			names = append(names, column.Name)
+		}
+		Expect(names).To(Equal([]string{
+			"by_country", "by_day",
+			"paid>revenue", "price.count", "price.min", "price.max", "price.avg", "price.sum", "latency.50.0", "latency.99.0",
+		}))
+		Expect(table.Columns[1].Dimension).To(BeTrue())
+		Expect(table.Columns[2].Dimension).To(BeFalse())
+
+		Expect(table.Rows).To(Equal([][]interface{}{
+			{"de", "2020-01-01", 20.0, 2.0, 1.0, 3.0, 2.0, 4.0, 10.0, 30.0},
+			{"de", "2020-01-02", 20.0, 1.0, 5.0, 5.0, 5.0, 5.0, 15.0, 15.0},
+			{"fr", "2020-01-01", 0.0, 1.0, 7.0, 7.0, 7.0, 7.0, 20.0, nil},
+		}))
+	})
+
+	It("should make a dimension per composite source", func() {
+		const response = `{
+			"aggregations": {
+				"pages": {
+					"after_key": {"country": "fr", "day": 1},
+					"buckets": [
+						{"key": {"day": 1, "country": "de"}, "doc_count": 2, "revenue": {"value": 10}},
+						{"key": {"day": 1, "country": "fr"}, "doc_count": 1, "revenue": {"value": 5}}
+					]
+				}
+			}
+		}`
+
+		pages := aggretastic.NewCompositeAggregation().Sources(
+			aggretastic.NewCompositeAggregationTermsValuesSource("country").Field("country"),
+			aggretastic.NewCompositeAggregationHistogramValuesSource("day", 1).Field("day"),
+		)
+		pages.Inject(aggretastic.NewSumAggregation().Field("price"), "revenue")
+		aggs := aggretastic.Aggregations{"pages": pages}
+
+		res := &elastic.SearchResult{}
+		Expect(json.Unmarshal([]byte(response), res)).To(Succeed())
+
+		table, err := aggs.Flatten(res)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(table.Columns).To(Equal([]aggretastic.Column{
+			{Name: "country", Dimension: true},
+			{Name: "day", Dimension: true},
+			{Name: "revenue"},
+		}))
+		Expect(table.Rows).To(Equal([][]interface{}{
+			{"de", 1.0, 10.0},
+			{"fr", 1.0, 5.0},
+		}))
+	})
+})
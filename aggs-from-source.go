@@ -0,0 +1,1519 @@
+package aggretastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/olivere/elastic/v7"
+)
+
+var (
+	ErrUnknownAggregation = fmt.Errorf("unknown aggregation type")
+	ErrInvalidSource      = fmt.Errorf("invalid aggregation source")
+)
+
+// FromSource rebuilds typed aggregations from their JSON-decoded source,
+// i.e. from the value of the "aggs" (or "aggregations") key of a search body:
+//
+//	{
+//	  "by_country": { "terms": { "field": "country" }, "aggs": { ... } },
+//	  "revenue":    { "sum": { "field": "price" } }
+//	}
+func FromSource(source map[string]interface{}) (Aggregations, error) {
+	result := make(Aggregations, len(source))
+
+	for name, raw := range source {
+		def, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: aggregation %q must be an object", ErrInvalidSource, name)
+		}
+
+		agg, err := AggregationFromSource(def)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		result[name] = agg
+	}
+
+	return result, nil
+}
+
+// AggregationFromSource rebuilds a single typed aggregation from its JSON-decoded source,
+// e.g. { "terms": { "field": "country" }, "aggs": { ... }, "meta": { ... } }
+func AggregationFromSource(source map[string]interface{}) (Aggregation, error) {
+	var (
+		typ      string
+		opts     interface{}
+		subs     = make(map[string]interface{})
+		subNames = make([]string, 0)
+		meta     map[string]interface{}
+		typeSet  bool
+	)
+
+	for key, value := range source {
+		switch key {
+		case "aggregations", "aggs":
+			switch m := value.(type) {
+			case *orderedSource:
+				// the source of aggretastic aggregation keeps the order of subAggregations
+				for _, name := range m.keys {
+					subs[name] = m.values[name]
+					subNames = append(subNames, name)
+				}
+			case map[string]interface{}:
+				// subAggregations are injected in the sorted order to keep the result stable
+				names := make([]string, 0, len(m))
+				for name, sub := range m {
+					subs[name] = sub
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				subNames = append(subNames, names...)
+			default:
+				return nil, fmt.Errorf("%w: %q must be an object", ErrInvalidSource, key)
+			}
+		case "meta":
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: %q must be an object", ErrInvalidSource, key)
+			}
+			meta = m
+		default:
+			if typeSet {
+				return nil, fmt.Errorf("%w: more than one aggregation type (%q, %q)", ErrInvalidSource, typ, key)
+			}
+			typ, opts, typeSet = key, value, true
+		}
+	}
+
+	if !typeSet {
+		return nil, fmt.Errorf("%w: aggregation type is missing", ErrInvalidSource)
+	}
+
+	parse, ok := aggregationParsers[typ]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAggregation, typ)
+	}
+
+	// "filter" is the only aggregation which body isn't an object of options but the query itself
+	optsMap, isMap := opts.(map[string]interface{})
+	if typ == "filter" {
+		optsMap = map[string]interface{}{"filter": opts}
+	} else if !isMap {
+		return nil, fmt.Errorf("%w: %q must be an object", ErrInvalidSource, typ)
+	}
+
+	r := newSourceReader(typ, optsMap, meta)
+	agg := parse(r)
+	if err := r.finish(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range subNames {
+		def, ok := subs[name].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: aggregation %q must be an object", ErrInvalidSource, name)
+		}
+
+		subAgg, err := AggregationFromSource(def)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if _, err := agg.Inject(subAgg, name); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return agg, nil
+}
+
+// UnmarshalJSON rebuilds typed aggregations from the JSON value of the "aggs" key of a search body.
+// The subAggregations keep the order of the JSON
+func (a *Aggregations) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	source := make(map[string]interface{}, len(raw))
+	for name, def := range raw {
+		value, err := decodeAggregationSource(def)
+		if err != nil {
+			return err
+		}
+		source[name] = value
+	}
+
+	aggs, err := FromSource(source)
+	if err != nil {
+		return err
+	}
+
+	*a = aggs
+	return nil
+}
+
+// decodeAggregationSource decodes the JSON of aggregation: its subAggregations become *orderedSource
+func decodeAggregationSource(raw json.RawMessage) (interface{}, error) {
+	var def map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &def); err != nil || def == nil {
+		// not an object: AggregationFromSource reports it
+		return decodeSourceValue(raw)
+	}
+
+	source := make(map[string]interface{}, len(def))
+	for key, value := range def {
+		var err error
+		if key == "aggregations" || key == "aggs" {
+			source[key], err = decodeOrderedAggregations(value)
+		} else {
+			source[key], err = decodeSourceValue(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return source, nil
+}
+
+// decodeOrderedAggregations decodes the object of named aggregations keeping the order of names
+func decodeOrderedAggregations(raw json.RawMessage) (interface{}, error) {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '{' {
+		return decodeSourceValue(raw)
+	}
+
+	keys, values, err := decodeOrderedObject(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &orderedSource{keys: make([]string, 0, len(keys)), values: make(map[string]interface{}, len(keys))}
+	for i, key := range keys {
+		if _, ok := source.values[key]; !ok {
+			source.keys = append(source.keys, key)
+		}
+		if source.values[key], err = decodeAggregationSource(values[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return source, nil
+}
+
+// decodeSourceValue decodes the JSON value keeping the numbers as json.Number
+func decodeSourceValue(raw json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var value interface{}
+	err := dec.Decode(&value)
+	return value, err
+}
+
+//
+// raw values
+//
+
+// rawQuery keeps the source of a query which was read from JSON
+type rawQuery struct {
+	source interface{}
+}
+
+func (q *rawQuery) Source() (interface{}, error) {
+	return q.source, nil
+}
+
+// rawSorter keeps the source of a sorter which was read from JSON
+type rawSorter struct {
+	source interface{}
+}
+
+func (s *rawSorter) Source() (interface{}, error) {
+	return s.source, nil
+}
+
+//
+// parsers
+//
+
+// aggregationParsers maps the aggregation type to its parser
+var aggregationParsers = map[string]func(r *sourceReader) Aggregation{
+	// bucket
+	"adjacency_matrix":    parseAdjacencyMatrixAggregation,
+	"children":            parseChildrenAggregation,
+	"composite":           parseCompositeAggregation,
+	"date_histogram":      parseDateHistogramAggregation,
+	"date_range":          parseDateRangeAggregation,
+	"diversified_sampler": parseDiversifiedSamplerAggregation,
+	"filter":              parseFilterAggregation,
+	"filters":             parseFiltersAggregation,
+	"geo_distance":        parseGeoDistanceAggregation,
+	"geohash_grid":        parseGeoHashGridAggregation,
+	"global":              parseGlobalAggregation,
+	"histogram":           parseHistogramAggregation,
+	"ip_range":            parseIPRangeAggregation,
+	"missing":             parseMissingAggregation,
+	"multi_terms":         parseMultiTermsAggregation,
+	"nested":              parseNestedAggregation,
+	"range":               parseRangeAggregation,
+	"reverse_nested":      parseReverseNestedAggregation,
+	"sampler":             parseSamplerAggregation,
+	"significant_terms":   parseSignificantTermsAggregation,
+	"significant_text":    parseSignificantTextAggregation,
+	"terms":               parseTermsAggregation,
+
+	// metrics
+	"avg":                       parseAvgAggregation,
+	"cardinality":               parseCardinalityAggregation,
+	"extended_stats":            parseExtendedStatsAggregation,
+	"geo_bounds":                parseGeoBoundsAggregation,
+	"geo_centroid":              parseGeoCentroidAggregation,
+	"matrix_stats":              parseMatrixStatsAggregation,
+	"max":                       parseMaxAggregation,
+	"median_absolute_deviation": parseMedianAbsoluteDeviationAggregation,
+	"min":                       parseMinAggregation,
+	"percentile_ranks":          parsePercentileRanksAggregation,
+	"percentiles":               parsePercentilesAggregation,
+	"scripted_metric":           parseScriptedMetricAggregation,
+	"stats":                     parseStatsAggregation,
+	"sum":                       parseSumAggregation,
+	"value_count":               parseValueCountAggregation,
+	"weighted_avg":              parseWeightedAvgAggregation,
+
+	// pipeline
+	"avg_bucket":         parseAvgBucketAggregation,
+	"bucket_script":      parseBucketScriptAggregation,
+	"bucket_selector":    parseBucketSelectorAggregation,
+	"bucket_sort":        parseBucketSortAggregation,
+	"cumulative_sum":     parseCumulativeSumAggregation,
+	"derivative":         parseDerivativeAggregation,
+	"max_bucket":         parseMaxBucketAggregation,
+	"min_bucket":         parseMinBucketAggregation,
+	"moving_avg":         parseMovAvgAggregation,
+	"percentiles_bucket": parsePercentilesBucketAggregation,
+	"serial_diff":        parseSerialDiffAggregation,
+	"stats_bucket":       parseStatsBucketAggregation,
+	"sum_bucket":         parseSumBucketAggregation,
+}
+
+// -- bucket --
+
+func parseAdjacencyMatrixAggregation(r *sourceReader) Aggregation {
+	a := NewAdjacencyMatrixAggregation()
+	a.meta = r.meta
+	for name, q := range r.namedQueries("filters") {
+		a.Filters(name, q)
+	}
+	return a
+}
+
+func parseChildrenAggregation(r *sourceReader) Aggregation {
+	a := NewChildrenAggregation()
+	a.meta = r.meta
+	a.typ = r.string("type")
+	return a
+}
+
+func parseCompositeAggregation(r *sourceReader) Aggregation {
+	a := NewCompositeAggregation()
+	a.meta = r.meta
+	a.size = r.intPtr("size")
+	a.after = r.object("after")
+
+	for i, raw := range r.array("sources") {
+		src := r.sub(fmt.Sprintf("sources[%d]", i), raw)
+		name, def := src.single()
+		if def == nil {
+			continue
+		}
+
+		typ, opts := def.single()
+		if opts == nil {
+			continue
+		}
+
+		switch typ {
+		case "terms":
+			s := NewCompositeAggregationTermsValuesSource(name)
+			s.field = opts.string("field")
+			s.script = opts.script("script")
+			s.valueType = opts.string("value_type")
+			s.missing = opts.value("missing")
+			s.order = opts.string("order")
+			a.Sources(s)
+		case "histogram":
+			s := NewCompositeAggregationHistogramValuesSource(name, opts.float64("interval"))
+			s.field = opts.string("field")
+			s.script = opts.script("script")
+			s.valueType = opts.string("value_type")
+			s.missing = opts.value("missing")
+			s.order = opts.string("order")
+			a.Sources(s)
+		case "date_histogram":
+			s := NewCompositeAggregationDateHistogramValuesSource(name, opts.value("interval"))
+			s.field = opts.string("field")
+			s.script = opts.script("script")
+			s.valueType = opts.string("value_type")
+			s.missing = opts.value("missing")
+			s.order = opts.string("order")
+			s.timeZone = opts.string("time_zone")
+			a.Sources(s)
+		default:
+			opts.fail(fmt.Errorf("%w: %q", ErrUnknownAggregation, typ))
+		}
+
+		opts.finishInto(r)
+		def.finishInto(r)
+		src.finishInto(r)
+	}
+
+	return a
+}
+
+func parseDateHistogramAggregation(r *sourceReader) Aggregation {
+	a := NewDateHistogramAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.missing = r.value("missing")
+	a.interval = r.string("interval")
+	a.fixedInterval = r.string("fixed_interval")
+	a.calendarInterval = r.string("calendar_interval")
+	a.minDocCount = r.int64Ptr("min_doc_count")
+	a.order, a.orderAsc = r.singleOrder("order")
+	a.timeZone = r.string("time_zone")
+	a.offset = r.string("offset")
+	a.format = r.string("format")
+	if bounds := r.object("extended_bounds"); bounds != nil {
+		b := r.sub("extended_bounds", bounds)
+		a.extendedBoundsMin = b.value("min")
+		a.extendedBoundsMax = b.value("max")
+		b.finishInto(r)
+	}
+	return a
+}
+
+func parseDateRangeAggregation(r *sourceReader) Aggregation {
+	a := NewDateRangeAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.keyed = r.boolPtr("keyed")
+	a.unmapped = r.boolPtr("unmapped")
+	a.timeZone = r.string("time_zone")
+	a.format = r.string("format")
+	for _, e := range r.ranges("ranges") {
+		a.entries = append(a.entries, DateRangeAggregationEntry{Key: e.key, From: e.from, To: e.to})
+	}
+	return a
+}
+
+func parseDiversifiedSamplerAggregation(r *sourceReader) Aggregation {
+	a := NewDiversifiedSamplerAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	if v := r.intPtr("shard_size"); v != nil {
+		a.shardSize = *v
+	}
+	if v := r.intPtr("max_docs_per_value"); v != nil {
+		a.maxDocsPerValue = *v
+	}
+	a.executionHint = r.string("execution_hint")
+	return a
+}
+
+func parseFilterAggregation(r *sourceReader) Aggregation {
+	a := NewFilterAggregation()
+	a.meta = r.meta
+	a.filter = r.query("filter")
+	return a
+}
+
+func parseFiltersAggregation(r *sourceReader) Aggregation {
+	a := NewFiltersAggregation()
+	a.meta = r.meta
+
+	switch filters := r.value("filters").(type) {
+	case nil:
+	case []interface{}:
+		for _, f := range filters {
+			a.unnamedFilters = append(a.unnamedFilters, &rawQuery{source: f})
+		}
+	case map[string]interface{}:
+		for name, f := range filters {
+			a.FilterWithName(name, &rawQuery{source: f})
+		}
+	default:
+		r.fail(fmt.Errorf("%w: %q must be an array or an object", ErrInvalidSource, "filters"))
+	}
+
+	return a
+}
+
+func parseGeoDistanceAggregation(r *sourceReader) Aggregation {
+	a := NewGeoDistanceAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.unit = r.string("unit")
+	a.distanceType = r.string("distance_type")
+	a.point = r.string("origin")
+	for _, e := range r.ranges("ranges") {
+		a.ranges = append(a.ranges, geoDistAggRange{Key: e.key, From: e.from, To: e.to})
+	}
+	return a
+}
+
+func parseGeoHashGridAggregation(r *sourceReader) Aggregation {
+	a := NewGeoHashGridAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.precision = r.value("precision")
+	if v := r.intPtr("size"); v != nil {
+		a.size = *v
+	}
+	if v := r.intPtr("shard_size"); v != nil {
+		a.shardSize = *v
+	}
+	return a
+}
+
+func parseGlobalAggregation(r *sourceReader) Aggregation {
+	a := NewGlobalAggregation()
+	a.meta = r.meta
+	return a
+}
+
+func parseHistogramAggregation(r *sourceReader) Aggregation {
+	a := NewHistogramAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.missing = r.value("missing")
+	a.interval = r.float64("interval")
+	a.order, a.orderAsc = r.singleOrder("order")
+	a.offset = r.float64Ptr("offset")
+	a.minDocCount = r.int64Ptr("min_doc_count")
+	if bounds := r.object("extended_bounds"); bounds != nil {
+		b := r.sub("extended_bounds", bounds)
+		a.minBounds = b.float64Ptr("min")
+		a.maxBounds = b.float64Ptr("max")
+		b.finishInto(r)
+	}
+	return a
+}
+
+func parseIPRangeAggregation(r *sourceReader) Aggregation {
+	a := NewIPRangeAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.keyed = r.boolPtr("keyed")
+	for i, raw := range r.array("ranges") {
+		e := r.sub(fmt.Sprintf("ranges[%d]", i), raw)
+		a.entries = append(a.entries, IPRangeAggregationEntry{
+			Key:  e.string("key"),
+			Mask: e.string("mask"),
+			From: e.string("from"),
+			To:   e.string("to"),
+		})
+		e.finishInto(r)
+	}
+	return a
+}
+
+func parseMissingAggregation(r *sourceReader) Aggregation {
+	a := NewMissingAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	return a
+}
+
+func parseMultiTermsAggregation(r *sourceReader) Aggregation {
+	a := NewMultiTermsAggregation()
+	a.meta = r.meta
+	for i, raw := range r.array("terms") {
+		t := r.sub(fmt.Sprintf("terms[%d]", i), raw)
+		a.terms = append(a.terms, &MultiTermsField{field: t.string("field"), missing: t.value("missing")})
+		t.finishInto(r)
+	}
+	a.size = r.intPtr("size")
+	a.shardSize = r.intPtr("shard_size")
+	a.minDocCount = r.intPtr("min_doc_count")
+	a.shardMinDocCount = r.intPtr("shard_min_doc_count")
+	a.showTermDocCountError = r.boolPtr("show_term_doc_count_error")
+	a.collectionMode = r.string("collect_mode")
+	a.order = r.termsOrder("order")
+	return a
+}
+
+func parseNestedAggregation(r *sourceReader) Aggregation {
+	a := NewNestedAggregation()
+	a.meta = r.meta
+	a.path = r.string("path")
+	return a
+}
+
+func parseRangeAggregation(r *sourceReader) Aggregation {
+	a := NewRangeAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.missing = r.value("missing")
+	a.keyed = r.boolPtr("keyed")
+	a.unmapped = r.boolPtr("unmapped")
+	for _, e := range r.ranges("ranges") {
+		a.entries = append(a.entries, rangeAggregationEntry{Key: e.key, From: e.from, To: e.to})
+	}
+	return a
+}
+
+func parseReverseNestedAggregation(r *sourceReader) Aggregation {
+	a := NewReverseNestedAggregation()
+	a.meta = r.meta
+	a.path = r.string("path")
+	return a
+}
+
+func parseSamplerAggregation(r *sourceReader) Aggregation {
+	a := NewSamplerAggregation()
+	a.meta = r.meta
+	if v := r.intPtr("shard_size"); v != nil {
+		a.shardSize = *v
+	}
+	if v := r.intPtr("max_docs_per_value"); v != nil {
+		a.maxDocsPerValue = *v
+	}
+	a.executionHint = r.string("execution_hint")
+	return a
+}
+
+func parseSignificantTermsAggregation(r *sourceReader) Aggregation {
+	a := NewSignificantTermsAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.requiredSize = r.intPtr("size")
+	a.shardSize = r.intPtr("shard_size")
+	a.minDocCount = r.intPtr("min_doc_count")
+	a.shardMinDocCount = r.intPtr("shard_min_doc_count")
+	a.executionHint = r.string("execution_hint")
+	a.filter = r.query("background_filter")
+	a.significanceHeuristic = r.significanceHeuristic()
+	return a
+}
+
+func parseSignificantTextAggregation(r *sourceReader) Aggregation {
+	a := NewSignificantTextAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+
+	thresholds := &BucketCountThresholds{
+		RequiredSize:     r.intPtr("size"),
+		ShardSize:        r.intPtr("shard_size"),
+		MinDocCount:      r.int64Ptr("min_doc_count"),
+		ShardMinDocCount: r.int64Ptr("shard_min_doc_count"),
+	}
+	if *thresholds != (BucketCountThresholds{}) {
+		a.bucketCountThresholds = thresholds
+	}
+
+	a.filter = r.query("background_filter")
+	a.significanceHeuristic = r.significanceHeuristic()
+	a.includeExclude = r.includeExclude()
+	return a
+}
+
+func parseTermsAggregation(r *sourceReader) Aggregation {
+	a := NewTermsAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.missing = r.value("missing")
+	a.size = r.intPtr("size")
+	a.shardSize = r.intPtr("shard_size")
+	a.requiredSize = r.intPtr("required_size")
+	a.minDocCount = r.intPtr("min_doc_count")
+	a.shardMinDocCount = r.intPtr("shard_min_doc_count")
+	a.showTermDocCountError = r.boolPtr("show_term_doc_count_error")
+	a.collectionMode = r.string("collect_mode")
+	a.valueType = r.string("value_type")
+	a.order = r.termsOrder("order")
+	a.includeExclude = r.includeExclude()
+	a.executionHint = r.string("execution_hint")
+	return a
+}
+
+// -- metrics --
+
+func parseAvgAggregation(r *sourceReader) Aggregation {
+	a := NewAvgAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	return a
+}
+
+func parseCardinalityAggregation(r *sourceReader) Aggregation {
+	a := NewCardinalityAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	a.precisionThreshold = r.int64Ptr("precision_threshold")
+	a.rehash = r.boolPtr("rehash")
+	return a
+}
+
+func parseExtendedStatsAggregation(r *sourceReader) Aggregation {
+	a := NewExtendedStatsAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	return a
+}
+
+func parseGeoBoundsAggregation(r *sourceReader) Aggregation {
+	a := NewGeoBoundsAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.wrapLongitude = r.boolPtr("wrap_longitude")
+	return a
+}
+
+func parseGeoCentroidAggregation(r *sourceReader) Aggregation {
+	a := NewGeoCentroidAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	return a
+}
+
+func parseMatrixStatsAggregation(r *sourceReader) Aggregation {
+	a := NewMatrixStatsAggregation()
+	a.meta = r.meta
+	a.fields = r.strings("fields")
+	a.missing = r.value("missing")
+	a.format = r.string("format")
+	a.valueType = r.value("value_type")
+	a.mode = r.string("mode")
+	return a
+}
+
+func parseMaxAggregation(r *sourceReader) Aggregation {
+	a := NewMaxAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	return a
+}
+
+func parseMedianAbsoluteDeviationAggregation(r *sourceReader) Aggregation {
+	a := NewMedianAbsoluteDeviationAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	if v := r.int64Ptr("compression"); v != nil {
+		a.compression = *v
+	}
+	a.missing = r.value("missing")
+	return a
+}
+
+func parseMinAggregation(r *sourceReader) Aggregation {
+	a := NewMinAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	return a
+}
+
+func parsePercentileRanksAggregation(r *sourceReader) Aggregation {
+	a := NewPercentileRanksAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	a.values = append(a.values, r.float64s("values")...)
+	a.compression = r.float64Ptr("compression")
+	a.estimator = r.string("estimator")
+	return a
+}
+
+func parsePercentilesAggregation(r *sourceReader) Aggregation {
+	a := NewPercentilesAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	a.percentiles = append(a.percentiles, r.float64s("percents")...)
+	a.compression = r.float64Ptr("compression")
+	a.estimator = r.string("estimator")
+	return a
+}
+
+func parseScriptedMetricAggregation(r *sourceReader) Aggregation {
+	a := NewScriptedMetricAggregation()
+	a.meta = r.meta
+	a.initScript = r.script("init_script")
+	a.mapScript = r.script("map_script")
+	a.combineScript = r.script("combine_script")
+	a.reduceScript = r.script("reduce_script")
+	a.params = r.object("params")
+	return a
+}
+
+func parseStatsAggregation(r *sourceReader) Aggregation {
+	a := NewStatsAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	return a
+}
+
+func parseSumAggregation(r *sourceReader) Aggregation {
+	a := NewSumAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	return a
+}
+
+func parseValueCountAggregation(r *sourceReader) Aggregation {
+	a := NewValueCountAggregation()
+	a.meta = r.meta
+	a.field = r.string("field")
+	a.script = r.script("script")
+	a.format = r.string("format")
+	return a
+}
+
+func parseWeightedAvgAggregation(r *sourceReader) Aggregation {
+	a := NewWeightedAvgAggregation()
+	a.meta = r.meta
+	for name, raw := range r.object("fields") {
+		a.fields[name] = r.fieldConfig("fields."+name, raw)
+	}
+	a.format = r.string("format")
+	a.valueType = r.string("value_type")
+	if raw := r.value("value"); raw != nil {
+		a.value = r.fieldConfig("value", raw)
+	}
+	if raw := r.value("weight"); raw != nil {
+		a.weight = r.fieldConfig("weight", raw)
+	}
+	return a
+}
+
+// -- pipeline --
+
+func parseAvgBucketAggregation(r *sourceReader) Aggregation {
+	a := NewAvgBucketAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+	return a
+}
+
+func parseBucketScriptAggregation(r *sourceReader) Aggregation {
+	a := NewBucketScriptAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.script = r.script("script")
+	a.bucketsPathsMap = r.stringsMap("buckets_path")
+	return a
+}
+
+func parseBucketSelectorAggregation(r *sourceReader) Aggregation {
+	a := NewBucketSelectorAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.script = r.script("script")
+	a.bucketsPathsMap = r.stringsMap("buckets_path")
+	return a
+}
+
+func parseBucketSortAggregation(r *sourceReader) Aggregation {
+	a := NewBucketSortAggregation()
+	a.meta = r.meta
+	if v := r.intPtr("from"); v != nil {
+		a.from = *v
+	}
+	if v := r.intPtr("size"); v != nil {
+		a.size = *v
+	}
+	a.gapPolicy = r.string("gap_policy")
+
+	sorters := r.value("sort")
+	if s, ok := sorters.([]interface{}); ok {
+		for _, sorter := range s {
+			a.sorters = append(a.sorters, parseSorter(sorter))
+		}
+	} else if sorters != nil {
+		a.sorters = append(a.sorters, parseSorter(sorters))
+	}
+
+	return a
+}
+
+func parseCumulativeSumAggregation(r *sourceReader) Aggregation {
+	a := NewCumulativeSumAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+	return a
+}
+
+func parseDerivativeAggregation(r *sourceReader) Aggregation {
+	a := NewDerivativeAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.unit = r.string("unit")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+	return a
+}
+
+func parseMaxBucketAggregation(r *sourceReader) Aggregation {
+	a := NewMaxBucketAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+	return a
+}
+
+func parseMinBucketAggregation(r *sourceReader) Aggregation {
+	a := NewMinBucketAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+	return a
+}
+
+func parseMovAvgAggregation(r *sourceReader) Aggregation {
+	a := NewMovAvgAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.window = r.intPtr("window")
+	a.predict = r.intPtr("predict")
+	a.minimize = r.boolPtr("minimize")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+
+	model := r.string("model")
+	settings := r.sub("settings", r.object("settings"))
+	switch model {
+	case "":
+	case "ewma":
+		a.model = &EWMAMovAvgModel{alpha: settings.float64Ptr("alpha")}
+	case "holt":
+		a.model = &HoltLinearMovAvgModel{alpha: settings.float64Ptr("alpha"), beta: settings.float64Ptr("beta")}
+	case "holt_winters":
+		a.model = &HoltWintersMovAvgModel{
+			alpha:           settings.float64Ptr("alpha"),
+			beta:            settings.float64Ptr("beta"),
+			gamma:           settings.float64Ptr("gamma"),
+			period:          settings.intPtr("period"),
+			pad:             settings.boolPtr("pad"),
+			seasonalityType: settings.string("type"),
+		}
+	case "linear":
+		a.model = NewLinearMovAvgModel()
+	case "simple":
+		a.model = NewSimpleMovAvgModel()
+	default:
+		r.fail(fmt.Errorf("%w: unknown moving_avg model %q", ErrInvalidSource, model))
+	}
+	settings.finishInto(r)
+
+	return a
+}
+
+func parsePercentilesBucketAggregation(r *sourceReader) Aggregation {
+	a := NewPercentilesBucketAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.percents = r.float64s("percents")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+	return a
+}
+
+func parseSerialDiffAggregation(r *sourceReader) Aggregation {
+	a := NewSerialDiffAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.lag = r.intPtr("lag")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+	return a
+}
+
+func parseStatsBucketAggregation(r *sourceReader) Aggregation {
+	a := NewStatsBucketAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+	return a
+}
+
+func parseSumBucketAggregation(r *sourceReader) Aggregation {
+	a := NewSumBucketAggregation()
+	a.meta = r.meta
+	a.format = r.string("format")
+	a.gapPolicy = r.string("gap_policy")
+	a.bucketsPaths = append(a.bucketsPaths, r.strings("buckets_path")...)
+	return a
+}
+
+// parseSorter reads the sorter in the form of { "field": { "order": "asc" } }
+// Any other form is kept as is
+func parseSorter(source interface{}) elastic.Sorter {
+	if field, ok := source.(string); ok {
+		return elastic.SortInfo{Field: field, Ascending: true}
+	}
+
+	m, ok := source.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return &rawSorter{source: source}
+	}
+
+	for field, raw := range m {
+		opts, ok := raw.(map[string]interface{})
+		if !ok || len(opts) != 1 {
+			break
+		}
+		switch opts["order"] {
+		case "asc":
+			return elastic.SortInfo{Field: field, Ascending: true}
+		case "desc":
+			return elastic.SortInfo{Field: field, Ascending: false}
+		}
+	}
+
+	return &rawSorter{source: source}
+}
+
+//
+// source reader
+//
+
+// sourceReader reads typed options of an aggregation source.
+// It remembers the first occurred error and the options which were read,
+// so any unsupported option is reported instead of being silently dropped.
+type sourceReader struct {
+	name string
+	opts map[string]interface{}
+	meta map[string]interface{}
+	read map[string]bool
+	err  error
+}
+
+func newSourceReader(name string, opts map[string]interface{}, meta map[string]interface{}) *sourceReader {
+	return &sourceReader{name: name, opts: opts, meta: meta, read: make(map[string]bool)}
+}
+
+// sub returns a reader of nested options, raw must be an object
+func (r *sourceReader) sub(name string, raw interface{}) *sourceReader {
+	sub := newSourceReader(r.name+"."+name, nil, nil)
+	if raw == nil {
+		return sub
+	}
+
+	opts, ok := raw.(map[string]interface{})
+	if !ok {
+		r.fail(fmt.Errorf("%w: %q must be an object", ErrInvalidSource, sub.name))
+		return sub
+	}
+	sub.opts = opts
+
+	return sub
+}
+
+// single returns the only key of the options and the reader of its value
+func (r *sourceReader) single() (string, *sourceReader) {
+	if len(r.opts) != 1 {
+		r.fail(fmt.Errorf("%w: %q must have exactly one key", ErrInvalidSource, r.name))
+		return "", nil
+	}
+	for key, value := range r.opts {
+		r.read[key] = true
+		return key, r.sub(key, value)
+	}
+	return "", nil
+}
+
+func (r *sourceReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *sourceReader) invalid(key string, expected string) {
+	r.fail(fmt.Errorf("%w: %s.%s must be %s", ErrInvalidSource, r.name, key, expected))
+}
+
+// finish returns the first error of reading or the error about unsupported options
+func (r *sourceReader) finish() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	unread := make([]string, 0)
+	for key := range r.opts {
+		if !r.read[key] {
+			unread = append(unread, key)
+		}
+	}
+	if len(unread) > 0 {
+		sort.Strings(unread)
+		return fmt.Errorf("%w: unsupported option %s.%s", ErrInvalidSource, r.name, unread[0])
+	}
+
+	return nil
+}
+
+// finishInto passes the result of the nested reader to the parent one
+func (r *sourceReader) finishInto(parent *sourceReader) {
+	if err := r.finish(); err != nil {
+		parent.fail(err)
+	}
+}
+
+func (r *sourceReader) value(key string) interface{} {
+	r.read[key] = true
+	return r.opts[key]
+}
+
+func (r *sourceReader) string(key string) string {
+	v := r.value(key)
+	if v == nil {
+		return ""
+	}
+	s, ok := v.(string)
+	if !ok {
+		r.invalid(key, "a string")
+	}
+	return s
+}
+
+func (r *sourceReader) float64Ptr(key string) *float64 {
+	v := r.value(key)
+	if v == nil {
+		return nil
+	}
+	f, ok := toFloat64(v)
+	if !ok {
+		r.invalid(key, "a number")
+		return nil
+	}
+	return &f
+}
+
+func (r *sourceReader) float64(key string) float64 {
+	if f := r.float64Ptr(key); f != nil {
+		return *f
+	}
+	return 0
+}
+
+func (r *sourceReader) int64Ptr(key string) *int64 {
+	if n, ok := r.opts[key].(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			r.read[key] = true
+			return &i
+		}
+	}
+
+	f := r.float64Ptr(key)
+	if f == nil {
+		return nil
+	}
+	if *f != math.Trunc(*f) {
+		r.invalid(key, "an integer")
+		return nil
+	}
+	i := int64(*f)
+	return &i
+}
+
+func (r *sourceReader) intPtr(key string) *int {
+	i64 := r.int64Ptr(key)
+	if i64 == nil {
+		return nil
+	}
+	i := int(*i64)
+	return &i
+}
+
+func (r *sourceReader) boolPtr(key string) *bool {
+	v := r.value(key)
+	if v == nil {
+		return nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		r.invalid(key, "a boolean")
+		return nil
+	}
+	return &b
+}
+
+func (r *sourceReader) object(key string) map[string]interface{} {
+	v := r.value(key)
+	if v == nil {
+		return nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		r.invalid(key, "an object")
+	}
+	return m
+}
+
+func (r *sourceReader) array(key string) []interface{} {
+	v := r.value(key)
+	if v == nil {
+		return nil
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		r.invalid(key, "an array")
+	}
+	return arr
+}
+
+// strings reads a string or an array of strings
+func (r *sourceReader) strings(key string) []string {
+	switch v := r.value(key).(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				r.invalid(key, "an array of strings")
+				return nil
+			}
+			result = append(result, s)
+		}
+		return result
+	default:
+		r.invalid(key, "a string or an array of strings")
+		return nil
+	}
+}
+
+func (r *sourceReader) stringsMap(key string) map[string]string {
+	switch v := r.value(key).(type) {
+	case nil:
+		return nil
+	case map[string]string:
+		return v
+	case map[string]interface{}:
+		result := make(map[string]string, len(v))
+		for k, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				r.invalid(key, "an object of strings")
+				return nil
+			}
+			result[k] = s
+		}
+		return result
+	default:
+		r.invalid(key, "an object of strings")
+		return nil
+	}
+}
+
+func (r *sourceReader) float64s(key string) []float64 {
+	switch v := r.value(key).(type) {
+	case nil:
+		return nil
+	case []float64:
+		return v
+	case []interface{}:
+		result := make([]float64, 0, len(v))
+		for _, item := range v {
+			f, ok := toFloat64(item)
+			if !ok {
+				r.invalid(key, "an array of numbers")
+				return nil
+			}
+			result = append(result, f)
+		}
+		return result
+	default:
+		r.invalid(key, "an array of numbers")
+		return nil
+	}
+}
+
+func (r *sourceReader) query(key string) elastic.Query {
+	v := r.value(key)
+	if v == nil {
+		return nil
+	}
+	return &rawQuery{source: v}
+}
+
+func (r *sourceReader) namedQueries(key string) map[string]elastic.Query {
+	result := make(map[string]elastic.Query)
+	for name, q := range r.object(key) {
+		result[name] = &rawQuery{source: q}
+	}
+	return result
+}
+
+// script reads the script either in the short form "doc.price.value * 2"
+// or in the full form { "source": "...", "lang": "...", "params": { ... } }
+func (r *sourceReader) script(key string) *elastic.Script {
+	switch v := r.value(key).(type) {
+	case nil:
+		return nil
+	case string:
+		// short form is kept as is: no type, no lang and no params
+		return elastic.NewScript(v).Type("").Params(nil)
+	case map[string]interface{}:
+		s := r.sub(key, v)
+		script := elastic.NewScript("")
+		if src := s.value("source"); src != nil {
+			script.Script(s.string("source"))
+		} else if inline := s.value("inline"); inline != nil {
+			script.Script(s.string("inline"))
+		} else if id := s.value("id"); id != nil {
+			script.Script(s.string("id")).Type("id")
+		}
+		if lang := s.string("lang"); lang != "" {
+			script.Lang(lang)
+		}
+		if params := s.object("params"); params != nil {
+			script.Params(params)
+		}
+		s.finishInto(r)
+		return script
+	default:
+		r.invalid(key, "a string or an object")
+		return nil
+	}
+}
+
+// singleOrder reads the order of histograms: { "_key": "asc" }
+func (r *sourceReader) singleOrder(key string) (string, bool) {
+	orders := r.termsOrder(key)
+	switch len(orders) {
+	case 0:
+		return "", false
+	case 1:
+		return orders[0].Field, orders[0].Ascending
+	default:
+		r.invalid(key, "a single order")
+		return "", false
+	}
+}
+
+// termsOrder reads either a single order { "_count": "desc" } or an array of them
+func (r *sourceReader) termsOrder(key string) []TermsOrder {
+	v := r.value(key)
+	if v == nil {
+		return nil
+	}
+
+	items, ok := v.([]interface{})
+	if !ok {
+		items = []interface{}{v}
+	}
+
+	orders := make([]TermsOrder, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			r.invalid(key, `an object like { "_count": "desc" } or an array of them`)
+			return nil
+		}
+
+		fields := make([]string, 0, len(m))
+		for field := range m {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			switch m[field] {
+			case "asc":
+				orders = append(orders, TermsOrder{Field: field, Ascending: true})
+			case "desc":
+				orders = append(orders, TermsOrder{Field: field, Ascending: false})
+			default:
+				r.invalid(key+"."+field, `"asc" or "desc"`)
+				return nil
+			}
+		}
+	}
+
+	return orders
+}
+
+func (r *sourceReader) includeExclude() *TermsAggregationIncludeExclude {
+	ie := &TermsAggregationIncludeExclude{}
+	set := false
+
+	switch include := r.value("include").(type) {
+	case nil:
+	case string:
+		ie.Include, set = include, true
+	case []interface{}:
+		ie.IncludeValues, set = include, true
+	case map[string]interface{}:
+		p := r.sub("include", include)
+		if v := p.intPtr("partition"); v != nil {
+			ie.Partition = *v
+		}
+		if v := p.intPtr("num_partitions"); v != nil {
+			ie.NumPartitions = *v
+		}
+		p.finishInto(r)
+		set = true
+	default:
+		r.invalid("include", "a string, an array or an object")
+	}
+
+	switch exclude := r.value("exclude").(type) {
+	case nil:
+	case string:
+		ie.Exclude, set = exclude, true
+	case []interface{}:
+		ie.ExcludeValues, set = exclude, true
+	default:
+		r.invalid("exclude", "a string or an array")
+	}
+
+	if !set {
+		return nil
+	}
+	return ie
+}
+
+func (r *sourceReader) significanceHeuristic() SignificanceHeuristic {
+	var heuristic SignificanceHeuristic
+
+	for _, name := range []string{"chi_square", "gnd", "jlh", "mutual_information", "percentage", "script_heuristic"} {
+		raw := r.value(name)
+		if raw == nil {
+			continue
+		}
+		if heuristic != nil {
+			r.fail(fmt.Errorf("%w: %s has more than one significance heuristic", ErrInvalidSource, r.name))
+			return nil
+		}
+
+		s := r.sub(name, raw)
+		switch name {
+		case "chi_square":
+			heuristic = &ChiSquareSignificanceHeuristic{
+				backgroundIsSuperset: s.boolPtr("background_is_superset"),
+				includeNegatives:     s.boolPtr("include_negatives"),
+			}
+		case "gnd":
+			heuristic = &GNDSignificanceHeuristic{backgroundIsSuperset: s.boolPtr("background_is_superset")}
+		case "jlh":
+			heuristic = NewJLHScoreSignificanceHeuristic()
+		case "mutual_information":
+			heuristic = &MutualInformationSignificanceHeuristic{
+				backgroundIsSuperset: s.boolPtr("background_is_superset"),
+				includeNegatives:     s.boolPtr("include_negatives"),
+			}
+		case "percentage":
+			heuristic = NewPercentageScoreSignificanceHeuristic()
+		case "script_heuristic":
+			heuristic = NewScriptSignificanceHeuristic().Script(s.script("script"))
+		}
+		s.finishInto(r)
+	}
+
+	return heuristic
+}
+
+func (r *sourceReader) fieldConfig(name string, raw interface{}) *MultiValuesSourceFieldConfig {
+	f := r.sub(name, raw)
+	config := &MultiValuesSourceFieldConfig{
+		FieldName: f.string("field"),
+		Missing:   f.value("missing"),
+		Script:    f.script("script"),
+		TimeZone:  f.string("time_zone"),
+	}
+	f.finishInto(r)
+	return config
+}
+
+type rangeEntrySource struct {
+	key      string
+	from, to interface{}
+}
+
+func (r *sourceReader) ranges(key string) []rangeEntrySource {
+	entries := make([]rangeEntrySource, 0)
+	for i, raw := range r.array(key) {
+		e := r.sub(fmt.Sprintf("%s[%d]", key, i), raw)
+		entries = append(entries, rangeEntrySource{
+			key:  e.string("key"),
+			from: numberOrValue(e.value("from")),
+			to:   numberOrValue(e.value("to")),
+		})
+		e.finishInto(r)
+	}
+	return entries
+}
+
+// toFloat64 converts any JSON-decoded (or hand-built) number to float64
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// numberOrValue converts json.Number to float64, any other value is returned as is
+func numberOrValue(v interface{}) interface{} {
+	if n, ok := v.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
@@ -0,0 +1,117 @@
+package aggretastic_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FromSource", func() {
+
+	const body = `{
+		"by_country": {
+			"terms": {"field": "country", "size": 10, "order": [{"revenue": "desc"}], "include": {"partition": 1, "num_partitions": 20}},
+			"aggregations": {
+				"by_day": {
+					"date_histogram": {"field": "created_at", "calendar_interval": "1d", "time_zone": "Europe/Berlin", "min_doc_count": 0},
+					"aggregations": {
+						"revenue": {"sum": {"field": "price", "script": {"source": "_value * params.rate", "params": {"rate": 1.5}}}},
+						"cost": {"sum": {"field": "cost"}},
+						"margin": {"bucket_script": {"buckets_path": {"a": "revenue", "b": "cost"}, "script": "params.a - params.b"}},
+						"prices": {"range": {"field": "price", "ranges": [{"to": 100}, {"from": 100, "to": 1000}, {"from": 1000}]}}
+					}
+				},
+				"revenue": {"sum": {"field": "price"}, "meta": {"currency": "EUR"}},
+				"countries": {"filter": {"term": {"active": true}}},
+				"growth": {"derivative": {"buckets_path": "revenue", "unit": "1d"}}
+			}
+		},
+		"pages": {
+			"composite": {
+				"size": 100,
+				"sources": [
+					{"country": {"terms": {"field": "country"}}},
+					{"day": {"date_histogram": {"field": "created_at", "interval": "1d", "order": "desc"}}}
+				]
+			}
+		}
+	}`
+
+	It("should rebuild a typed tree which has the same source", func() {
+		var aggs aggretastic.Aggregations
+		Expect(json.Unmarshal([]byte(body), &aggs)).To(Succeed())
+		Expect(aggs).To(HaveLen(2))
+
+		Expect(aggs.Select("by_country")).To(BeAssignableToTypeOf(&aggretastic.TermsAggregation{}))
+		Expect(aggs.Select("by_country", "by_day")).To(BeAssignableToTypeOf(&aggretastic.DateHistogramAggregation{}))
+		Expect(aggs.Select("by_country", "by_day", "margin")).To(BeAssignableToTypeOf(&aggretastic.BucketScriptAggregation{}))
+		Expect(aggs.Select("by_country", "growth")).To(BeAssignableToTypeOf(&aggretastic.DerivativeAggregation{}))
+		Expect(aggs.Select("pages")).To(BeAssignableToTypeOf(&aggretastic.CompositeAggregation{}))
+
+		var expected map[string]interface{}
+		Expect(json.Unmarshal([]byte(body), &expected)).To(Succeed())
+
+		for name, agg := range aggs {
+			src, err := agg.Source()
+			Expect(err).ShouldNot(HaveOccurred())
+			actual, err := json.Marshal(src)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			want, _ := json.Marshal(expected[name])
+			Expect(actual).To(MatchJSON(want))
+		}
+	})
+
+	It("should keep the order of subAggregations", func() {
+		// the keys of aggregation objects are sorted by json.Marshal, only the subAggregations keep their order
+		const unsorted = `{"by_country":{"aggregations":{"z":{"sum":{"field":"price"}},"a":{"aggregations":{"y":{"min":{"field":"price"}},"b":{"avg":{"field":"price"}}},"terms":{"field":"city"}},"m":{"value_count":{"field":"id"}}},"terms":{"field":"country"}}}`
+
+		var aggs aggretastic.Aggregations
+		Expect(json.Unmarshal([]byte(unsorted), &aggs)).To(Succeed())
+		Expect(aggs.Select("by_country").GetSubNames()).To(Equal([]string{"z", "a", "m"}))
+
+		src, err := aggs.Select("by_country").Source()
+		Expect(err).ShouldNot(HaveOccurred())
+		actual, err := json.Marshal(map[string]interface{}{"by_country": src})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(actual)).To(Equal(unsorted))
+	})
+
+	It("should allow to modify the rebuilt tree", func() {
+		var aggs aggretastic.Aggregations
+		Expect(json.Unmarshal([]byte(body), &aggs)).To(Succeed())
+
+		resultPaths, err := aggs.Inject(aggretastic.NewAvgAggregation().Field("price"), "by_country", "by_day", "avg_price")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(resultPaths).To(Equal([][]string{{"by_country", "by_day", "avg_price"}}))
+		Expect(aggs.Pop("by_country", "countries")).To(BeAssignableToTypeOf(&aggretastic.FilterAggregation{}))
+	})
+
+	It("should report unknown aggregations", func() {
+		_, err := aggretastic.FromSource(map[string]interface{}{
+			"x": map[string]interface{}{"unknown_agg": map[string]interface{}{}},
+		})
+		Expect(errors.Is(err, aggretastic.ErrUnknownAggregation)).To(BeTrue())
+	})
+
+	It("should report unsupported options instead of dropping them", func() {
+		_, err := aggretastic.FromSource(map[string]interface{}{
+			"x": map[string]interface{}{"terms": map[string]interface{}{"field": "a", "fieldz": "b"}},
+		})
+		Expect(errors.Is(err, aggretastic.ErrInvalidSource)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("terms.fieldz"))
+	})
+
+	It("should not allow subAggregations under pipeline aggregations", func() {
+		_, err := aggretastic.FromSource(map[string]interface{}{
+			"x": map[string]interface{}{
+				"max_bucket":   map[string]interface{}{"buckets_path": "a>b"},
+				"aggregations": map[string]interface{}{"y": map[string]interface{}{"sum": map[string]interface{}{"field": "z"}}},
+			},
+		})
+		Expect(errors.Is(err, aggretastic.ErrAggIsNotInjectable)).To(BeTrue())
+	})
+})
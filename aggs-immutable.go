@@ -0,0 +1,141 @@
+package aggretastic
+
+// With returns a new tree with subAgg set by path. The original tree is not changed.
+// The new tree shares the untouched subtrees with the original one: only the aggregations on the path are copied.
+// So both trees have to be treated as immutable: build the next versions with With/Without too
+// or Clone the tree before changing it in place
+func With(agg Aggregation, path []string, subAgg Aggregation) (Aggregation, error) {
+	return with(agg, path, subAgg)
+}
+
+// Without returns a new tree without the subAgg located by path. The original tree is not changed.
+// The trees share the untouched subtrees, see With()
+func Without(agg Aggregation, path []string) (Aggregation, error) {
+	return without(agg, path)
+}
+
+// With returns a new map of aggregations with the aggregation set by path. See With()
+func (a *Aggregations) With(path []string, subAgg Aggregation) (Aggregations, error) {
+	if len(path) == 0 {
+		return nil, &PathError{Op: "With", Err: ErrNoPath}
+	}
+
+	result := a.shallowCopy()
+	if len(path) == 1 {
+		result[path[0]] = subAgg
+		return result, nil
+	}
+
+	agg, ok := result[path[0]]
+	if !ok {
+		return nil, &PathError{Op: "With", Path: path[:1], Err: ErrPathNotSelectable}
+	}
+
+	agg, err := with(agg, path[1:], subAgg)
+	if err != nil {
+		return nil, withPathPrefix("With", path[:1], err)
+	}
+
+	result[path[0]] = agg
+	return result, nil
+}
+
+// Without returns a new map of aggregations without the aggregation located by path. See Without()
+func (a *Aggregations) Without(path []string) (Aggregations, error) {
+	if len(path) == 0 {
+		return nil, &PathError{Op: "Without", Err: ErrNoPath}
+	}
+
+	result := a.shallowCopy()
+	agg, ok := result[path[0]]
+	if !ok {
+		return nil, &PathError{Op: "Without", Path: path[:1], Err: ErrSubAggNotFound}
+	}
+
+	if len(path) == 1 {
+		delete(result, path[0])
+		return result, nil
+	}
+
+	agg, err := without(agg, path[1:])
+	if err != nil {
+		return nil, withPathPrefix("Without", path[:1], err)
+	}
+
+	result[path[0]] = agg
+	return result, nil
+}
+
+func (a *Aggregations) shallowCopy() Aggregations {
+	result := make(Aggregations)
+	if a != nil {
+		for name, agg := range *a {
+			result[name] = agg
+		}
+	}
+	return result
+}
+
+func with(agg Aggregation, path []string, subAgg Aggregation) (Aggregation, error) {
+	if len(path) == 0 {
+		return nil, &PathError{Op: "With", Err: ErrNoPath}
+	}
+
+	if len(path) > 1 {
+		child := agg.Select(path[0])
+		if child == nil {
+			return nil, &PathError{Op: "With", Path: path[:1], Err: ErrPathNotSelectable}
+		}
+
+		var err error
+		if subAgg, err = with(child, path[1:], subAgg); err != nil {
+			return nil, withPathPrefix("With", path[:1], err)
+		}
+	}
+
+	if !isBucketAggregation(agg) {
+		return nil, &PathError{Op: "With", Path: []string{}, Node: agg, Err: ErrAggIsNotInjectable}
+	}
+
+	c := shareSubAggregations(agg)
+	if _, err := c.Inject(subAgg, path[0]); err != nil {
+		return nil, withPathPrefix("With", nil, err)
+	}
+	return c, nil
+}
+
+func without(agg Aggregation, path []string) (Aggregation, error) {
+	if len(path) == 0 {
+		return nil, &PathError{Op: "Without", Err: ErrNoPath}
+	}
+
+	child := agg.Select(path[0])
+	if child == nil {
+		return nil, &PathError{Op: "Without", Path: path[:1], Err: ErrSubAggNotFound}
+	}
+
+	c := shareSubAggregations(agg)
+	if len(path) == 1 {
+		c.Pop(path[0])
+		return c, nil
+	}
+
+	child, err := without(child, path[1:])
+	if err != nil {
+		return nil, withPathPrefix("Without", path[:1], err)
+	}
+
+	if _, err := c.Inject(child, path[0]); err != nil {
+		return nil, withPathPrefix("Without", nil, err)
+	}
+	return c, nil
+}
+
+// shareSubAggregations returns a copy of aggregation which shares its subAggregations.
+// The aggregations which don't support it are copied deeply
+func shareSubAggregations(agg Aggregation) Aggregation {
+	if sharer, ok := agg.(treeSharer); ok {
+		return sharer.cloneWith(sharer.shareFor)
+	}
+	return agg.Clone()
+}
@@ -0,0 +1,83 @@
+package aggretastic_test
+
+import (
+	"errors"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("With and Without", func() {
+
+	var base *aggretastic.TermsAggregation
+
+	BeforeEach(func() {
+		base = aggretastic.NewTermsAggregation().Field("country")
+		base.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+		base.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "revenue")
+		base.Inject(aggretastic.NewTermsAggregation().Field("city"), "by_city")
+		base.Inject(aggretastic.NewCardinalityAggregation().Field("customer"), "by_city", "customers")
+	})
+
+	It("should add an aggregation sharing the untouched subtrees", func() {
+		before, _ := base.Source()
+
+		result, err := aggretastic.With(base, []string{"by_day", "avg_price"}, aggretastic.NewAvgAggregation().Field("price"))
+		Expect(err).NotTo(HaveOccurred())
+
+		after, _ := base.Source()
+		Expect(after).To(Equal(before))
+		Expect(base.Select("by_day", "avg_price")).To(BeNil())
+
+		Expect(result).NotTo(BeIdenticalTo(base))
+		Expect(result.GetSubNames()).To(Equal([]string{"by_day", "by_city"}))
+		Expect(result.Select("by_day")).NotTo(BeIdenticalTo(base.Select("by_day")))
+		Expect(result.Select("by_day").GetSubNames()).To(Equal([]string{"revenue", "avg_price"}))
+		Expect(result.Select("by_day", "revenue")).To(BeIdenticalTo(base.Select("by_day", "revenue")))
+		Expect(result.Select("by_city")).To(BeIdenticalTo(base.Select("by_city")))
+
+		src, _ := result.Source()
+		Expect(src).To(HaveKeyWithValue("terms", HaveKeyWithValue("field", "country")))
+	})
+
+	It("should replace and remove aggregations keeping the original tree", func() {
+		replaced, err := aggretastic.With(base, []string{"by_day"}, aggretastic.NewHistogramAggregation().Field("price").Interval(10))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replaced.GetSubNames()).To(Equal([]string{"by_day", "by_city"}))
+		Expect(replaced.Select("by_day")).To(BeAssignableToTypeOf(&aggretastic.HistogramAggregation{}))
+		Expect(base.Select("by_day")).To(BeAssignableToTypeOf(&aggretastic.DateHistogramAggregation{}))
+
+		result, err := aggretastic.Without(base, []string{"by_city", "customers"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Select("by_city").GetSubNames()).To(BeEmpty())
+		Expect(base.Select("by_city", "customers")).NotTo(BeNil())
+		Expect(result.Select("by_day")).To(BeIdenticalTo(base.Select("by_day")))
+	})
+
+	It("should check the paths", func() {
+		_, err := aggretastic.With(base, []string{"by_day", "revenue", "x"}, aggretastic.NewAvgAggregation())
+		Expect(errors.Is(err, aggretastic.ErrAggIsNotInjectable)).To(BeTrue())
+		Expect(err.Error()).To(Equal("With by_day>revenue (*aggretastic.SumAggregation): agg is not injectable"))
+
+		_, err = aggretastic.With(base, []string{"by_week", "x"}, aggretastic.NewAvgAggregation())
+		Expect(errors.Is(err, aggretastic.ErrPathNotSelectable)).To(BeTrue())
+
+		_, err = aggretastic.Without(base, []string{"by_day", "x"})
+		Expect(errors.Is(err, aggretastic.ErrSubAggNotFound)).To(BeTrue())
+	})
+
+	It("should work with the maps of aggregations", func() {
+		aggs := aggretastic.Aggregations{"by_country": base}
+
+		result, err := aggs.With([]string{"by_country", "by_day", "avg_price"}, aggretastic.NewAvgAggregation().Field("price"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Select("by_country", "by_day", "avg_price")).NotTo(BeNil())
+		Expect(aggs.Select("by_country", "by_day", "avg_price")).To(BeNil())
+
+		result, err = result.Without([]string{"by_country"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(BeEmpty())
+		Expect(aggs).To(HaveKey("by_country"))
+	})
+})
@@ -0,0 +1,55 @@
+package aggretastic
+
+// isMultiBucketAggregation reports whether the aggregation splits documents into many buckets
+func isMultiBucketAggregation(agg Aggregation) bool {
+	switch agg.(type) {
+	case *TermsAggregation, *MultiTermsAggregation, *SignificantTermsAggregation, *SignificantTextAggregation,
+		*HistogramAggregation, *DateHistogramAggregation,
+		*RangeAggregation, *DateRangeAggregation, *IPRangeAggregation, *GeoDistanceAggregation,
+		*GeoHashGridAggregation, *CompositeAggregation, *FiltersAggregation, *AdjacencyMatrixAggregation:
+		return true
+	}
+	return false
+}
+
+// isSingleBucketAggregation reports whether the aggregation collects documents into the only bucket
+func isSingleBucketAggregation(agg Aggregation) bool {
+	switch agg.(type) {
+	case *FilterAggregation, *GlobalAggregation, *MissingAggregation, *NestedAggregation, *ReverseNestedAggregation,
+		*ChildrenAggregation, *SamplerAggregation, *DiversifiedSamplerAggregation:
+		return true
+	}
+	return false
+}
+
+// isBucketAggregation reports whether the aggregation creates buckets and is allowed to have subAggregations
+func isBucketAggregation(agg Aggregation) bool {
+	return isMultiBucketAggregation(agg) || isSingleBucketAggregation(agg)
+}
+
+// isPipelineAggregation reports whether the aggregation works on the outputs of other aggregations
+func isPipelineAggregation(agg Aggregation) bool {
+	switch agg.(type) {
+	case *AvgBucketAggregation, *SumBucketAggregation, *MinBucketAggregation, *MaxBucketAggregation,
+		*StatsBucketAggregation, *PercentilesBucketAggregation,
+		*DerivativeAggregation, *CumulativeSumAggregation, *SerialDiffAggregation, *MovAvgAggregation,
+		*BucketScriptAggregation, *BucketSelectorAggregation, *BucketSortAggregation:
+		return true
+	}
+	return false
+}
+
+// isSiblingPipelineAggregation reports whether the pipeline aggregation works on the buckets of its sibling multi-bucket aggregation
+func isSiblingPipelineAggregation(agg Aggregation) bool {
+	switch agg.(type) {
+	case *AvgBucketAggregation, *SumBucketAggregation, *MinBucketAggregation, *MaxBucketAggregation,
+		*StatsBucketAggregation, *PercentilesBucketAggregation:
+		return true
+	}
+	return false
+}
+
+// isMetricAggregation reports whether the aggregation computes metrics over the documents of the bucket
+func isMetricAggregation(agg Aggregation) bool {
+	return !isBucketAggregation(agg) && !isPipelineAggregation(agg)
+}
@@ -0,0 +1,34 @@
+package aggretastic
+
+import "sync/atomic"
+
+// Logger receives the diagnostics of tree operations e.g. ignored selects through pipeline aggregations.
+// *log.Logger implements it
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// loggerHolder keeps the Logger in atomic.Value which requires the same concrete type of stored values
+type loggerHolder struct {
+	logger Logger
+}
+
+var packageLogger atomic.Value
+
+// SetLogger sets the logger of the package. The package is silent by default; nil makes it silent again
+func SetLogger(logger Logger) {
+	packageLogger.Store(loggerHolder{logger: logger})
+}
+
+// currentLogger returns the logger of the package or nil
+func currentLogger() Logger {
+	holder, _ := packageLogger.Load().(loggerHolder)
+	return holder.logger
+}
+
+// logf reports through the logger of the package if it's set
+func logf(format string, v ...interface{}) {
+	if logger := currentLogger(); logger != nil {
+		logger.Printf(format, v...)
+	}
+}
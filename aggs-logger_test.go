@@ -0,0 +1,93 @@
+package aggretastic_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+var _ = Describe("Logger", func() {
+
+	var agg *aggretastic.TermsAggregation
+
+	BeforeEach(func() {
+		agg = aggretastic.NewTermsAggregation().Field("country")
+		agg.Inject(aggretastic.NewSumAggregation().Field("price"), "revenue")
+		agg.Inject(aggretastic.NewDerivativeAggregation().BucketsPath("revenue"), "growth")
+	})
+
+	AfterEach(func() {
+		aggretastic.SetLogger(nil)
+	})
+
+	It("should be silent by default", func() {
+		// the reset logger doesn't receive anything
+		logger := &recordingLogger{}
+		aggretastic.SetLogger(logger)
+		aggretastic.SetLogger(nil)
+
+		// nothing is written to stdout or the standard logger
+		stdout := os.Stdout
+		r, w, err := os.Pipe()
+		Expect(err).NotTo(HaveOccurred())
+		os.Stdout = w
+		logged := &bytes.Buffer{}
+		log.SetOutput(logged)
+
+		Expect(agg.Select("growth", "x")).To(BeNil())
+		Expect(agg.Pop("growth", "x")).To(BeNil())
+		aggs := aggretastic.Aggregations{"by_country": agg}
+		_, err = aggs.InjectSafe(aggretastic.NewTermsAggregation().Field("city"), "by_country")
+
+		os.Stdout = stdout
+		log.SetOutput(os.Stderr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		printed, err := ioutil.ReadAll(r)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(printed)).To(BeEmpty())
+		Expect(logged.String()).To(BeEmpty())
+		Expect(logger.messages).To(BeEmpty())
+	})
+
+	It("should report ignored selects through pipelines", func() {
+		logger := &recordingLogger{}
+		aggretastic.SetLogger(logger)
+
+		Expect(agg.Select("growth", "x")).To(BeNil())
+		Expect(agg.Pop("growth", "x")).To(BeNil())
+		Expect(logger.messages).To(HaveLen(2))
+		Expect(logger.messages[0]).To(HavePrefix("notInjectable.Select() is ignored"))
+		Expect(logger.messages[1]).To(HavePrefix("notInjectable.Pop() is ignored"))
+
+		aggretastic.SetLogger(nil)
+		agg.Select("growth", "x")
+		Expect(logger.messages).To(HaveLen(2))
+	})
+
+	It("should report injecting into the existing aggregation of the map", func() {
+		logger := &recordingLogger{}
+		aggretastic.SetLogger(logger)
+
+		aggs := aggretastic.Aggregations{"by_country": agg}
+		_, err := aggs.InjectSafe(aggretastic.NewTermsAggregation().Field("city"), "by_country")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(aggs["by_country"]).To(BeIdenticalTo(agg))
+		Expect(logger.messages).To(ConsistOf(HavePrefix("warning! maybe unexpected behaviour")))
+	})
+})
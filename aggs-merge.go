@@ -0,0 +1,268 @@
+package aggretastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var ErrMergeConflict = fmt.Errorf("merge conflict")
+
+// MergePolicy defines what to do when both trees have different aggregations with the same name
+type MergePolicy int
+
+const (
+	// MergeKeepLeft keeps the aggregation of dst (with its subAggregations)
+	MergeKeepLeft MergePolicy = iota
+
+	// MergeKeepRight replaces the aggregation of dst with the one of src keeping its position
+	MergeKeepRight
+
+	// MergeErrorOnConflict fails the merge; dst is not changed
+	MergeErrorOnConflict
+
+	// MergeRenameOnConflict adds the aggregation of src with a suffixed name: "revenue_2", "revenue_3" etc.
+	// The buckets paths of src pipelines which refer the renamed aggregation are rewritten
+	MergeRenameOnConflict
+)
+
+// MergeConflict describes two different aggregations with the same path
+type MergeConflict struct {
+	// Path of the aggregation in dst
+	Path []string
+
+	// Left is the aggregation of dst, Right is the aggregation of src
+	Left  Aggregation
+	Right Aggregation
+
+	// RenamedTo is the new name of the right aggregation when MergeRenameOnConflict is used
+	RenamedTo string
+}
+
+// MergeReport is the result of merge
+type MergeReport struct {
+	// Added are the paths of aggregations added to dst
+	Added [][]string
+
+	// Conflicts are the aggregations which have the same path and different definitions
+	Conflicts []MergeConflict
+}
+
+// Merge merges the subAggregations of src into dst.
+// The aggregations with the same name are compared by their definitions (ignoring their subAggregations):
+// identical aggregations are unified and their subAggregations are merged recursively,
+// different ones are resolved with the policy and reported.
+// The roots themselves are not compared: they are containers of merged subAggregations.
+// The src is not changed; its aggregations are cloned
+func Merge(dst, src Aggregation, policy MergePolicy) (*MergeReport, error) {
+	clone := src.Clone()
+	renameSrc := func(path []string, newName string) error { return Rename(clone, path, newName) }
+	return merge(&treeMergeContainer{dst}, &treeMergeContainer{clone}, renameSrc, policy)
+}
+
+// Merge merges the aggregations of src into the map. See Merge()
+func (a *Aggregations) Merge(src Aggregations, policy MergePolicy) (*MergeReport, error) {
+	clone := src.Clone()
+	return merge(&mapMergeContainer{a}, &mapMergeContainer{&clone}, clone.Rename, policy)
+}
+
+func merge(dst, src mergeContainer, renameSrc func(path []string, newName string) error, policy MergePolicy) (*MergeReport, error) {
+	if policy == MergeErrorOnConflict {
+		// dry run to keep dst unchanged on conflicts
+		m := &merger{policy: policy, renameSrc: renameSrc, dryRun: true, report: &MergeReport{}}
+		if err := m.merge(nil, dst, src); err != nil {
+			return nil, err
+		}
+		if len(m.report.Conflicts) > 0 {
+			c := m.report.Conflicts[0]
+			return m.report, fmt.Errorf("%w: %s", ErrMergeConflict, strings.Join(c.Path, ">"))
+		}
+	}
+
+	m := &merger{policy: policy, renameSrc: renameSrc, report: &MergeReport{}}
+	if err := m.merge(nil, dst, src); err != nil {
+		return nil, err
+	}
+
+	return m.report, nil
+}
+
+type merger struct {
+	policy MergePolicy
+
+	// renameSrc renames an aggregation of src rewriting buckets paths of the whole src tree
+	renameSrc func(path []string, newName string) error
+
+	dryRun bool
+	report *MergeReport
+}
+
+func (m *merger) merge(path []string, dst, src mergeContainer) error {
+	for _, name := range src.names() {
+		right := src.get(name)
+		if right == nil {
+			// renamed by the previous conflict resolution
+			continue
+		}
+		left := dst.get(name)
+		subPath := joinPath(path, name)
+
+		if left == nil {
+			if err := m.add(dst, subPath, right); err != nil {
+				return err
+			}
+			continue
+		}
+
+		same, err := sameDefinition(left, right)
+		if err != nil {
+			return err
+		}
+
+		if same {
+			if err := m.merge(subPath, &treeMergeContainer{left}, &treeMergeContainer{right}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := m.resolve(dst, src, subPath, left, right); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *merger) add(dst mergeContainer, path []string, agg Aggregation) error {
+	if !m.dryRun {
+		if err := dst.set(path[len(path)-1], agg); err != nil {
+			return fmt.Errorf("%w: %s", err, strings.Join(path, ">"))
+		}
+	}
+
+	m.report.Added = append(m.report.Added, path)
+	return nil
+}
+
+func (m *merger) resolve(dst, src mergeContainer, path []string, left, right Aggregation) error {
+	conflict := MergeConflict{Path: path, Left: left, Right: right}
+	name := path[len(path)-1]
+
+	switch m.policy {
+	case MergeKeepRight:
+		if !m.dryRun {
+			if err := dst.set(name, right); err != nil {
+				return fmt.Errorf("%w: %s", err, strings.Join(path, ">"))
+			}
+		}
+
+	case MergeRenameOnConflict:
+		conflict.RenamedTo = freeName(name, dst, src)
+		if !m.dryRun {
+			if err := m.renameSrc(path, conflict.RenamedTo); err != nil {
+				return err
+			}
+			if err := m.add(dst, joinPath(path[:len(path)-1], conflict.RenamedTo), right); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.report.Conflicts = append(m.report.Conflicts, conflict)
+	return nil
+}
+
+// freeName returns the name with the first suffix which is used neither in dst nor in src
+func freeName(name string, dst, src mergeContainer) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s_%d", name, i)
+		if dst.get(candidate) == nil && src.get(candidate) == nil {
+			return candidate
+		}
+	}
+}
+
+// sameDefinition compares the sources of aggregations without their subAggregations
+func sameDefinition(a, b Aggregation) (bool, error) {
+	aDef, err := definitionOf(a)
+	if err != nil {
+		return false, err
+	}
+	bDef, err := definitionOf(b)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(aDef, bDef), nil
+}
+
+// definitionOf returns the JSON source of aggregation without its subAggregations
+func definitionOf(agg Aggregation) ([]byte, error) {
+	src, err := agg.Source()
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := src.(map[string]interface{}); ok {
+		def := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			if k != "aggregations" && k != "aggs" {
+				def[k] = v
+			}
+		}
+		src = def
+	}
+
+	return json.Marshal(src)
+}
+
+// mergeContainer is a level of merged tree: subAggregations of an aggregation or the map of aggregations
+type mergeContainer interface {
+	names() []string
+	get(name string) Aggregation
+	set(name string, agg Aggregation) error
+}
+
+type treeMergeContainer struct {
+	agg Aggregation
+}
+
+// subAggregationGetter is implemented by every aggregation with *tree
+type subAggregationGetter interface {
+	subAggregation(name string) Aggregation
+}
+
+func (c *treeMergeContainer) names() []string {
+	return c.agg.GetSubNames()
+}
+
+func (c *treeMergeContainer) get(name string) Aggregation {
+	if getter, ok := c.agg.(subAggregationGetter); ok {
+		return getter.subAggregation(name)
+	}
+	return c.agg.GetAllSubs()[name]
+}
+
+func (c *treeMergeContainer) set(name string, agg Aggregation) error {
+	_, err := c.agg.Inject(agg, name)
+	return err
+}
+
+type mapMergeContainer struct {
+	aggs *Aggregations
+}
+
+func (c *mapMergeContainer) names() []string {
+	return sortedNames(*c.aggs)
+}
+
+func (c *mapMergeContainer) get(name string) Aggregation {
+	return (*c.aggs)[name]
+}
+
+func (c *mapMergeContainer) set(name string, agg Aggregation) error {
+	(*c.aggs)[name] = agg
+	return nil
+}
@@ -0,0 +1,80 @@
+package aggretastic_test
+
+import (
+	"errors"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Merge", func() {
+
+	var left, right aggretastic.Aggregations
+
+	BeforeEach(func() {
+		byCountry := aggretastic.NewTermsAggregation().Field("country").Size(10)
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "revenue")
+		byCountry.Inject(aggretastic.NewAvgAggregation().Field("price"), "avg_price")
+		left = aggretastic.Aggregations{"by_country": byCountry}
+
+		sameCountry := aggretastic.NewTermsAggregation().Field("country").Size(10)
+		sameCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "revenue")
+		sameCountry.Inject(aggretastic.NewSumAggregation().Field("net_price"), "avg_price")
+		sameCountry.Inject(aggretastic.NewCardinalityAggregation().Field("customer"), "customers")
+		sameCountry.Inject(aggretastic.NewMaxBucketAggregation().BucketsPath("avg_price"), "x")
+		right = aggretastic.Aggregations{
+			"by_country": sameCountry,
+			"total":      aggretastic.NewSumAggregation().Field("price"),
+		}
+	})
+
+	It("should unify identical aggregations and keep the left on conflict", func() {
+		report, err := left.Merge(right, aggretastic.MergeKeepLeft)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(report.Added).To(Equal([][]string{{"by_country", "customers"}, {"by_country", "x"}, {"total"}}))
+		Expect(report.Conflicts).To(HaveLen(1))
+		Expect(report.Conflicts[0].Path).To(Equal([]string{"by_country", "avg_price"}))
+		Expect(report.Conflicts[0].Left).To(BeAssignableToTypeOf(&aggretastic.AvgAggregation{}))
+		Expect(report.Conflicts[0].Right).To(BeAssignableToTypeOf(&aggretastic.SumAggregation{}))
+
+		Expect(left.Select("by_country").GetSubNames()).To(Equal([]string{"revenue", "avg_price", "customers", "x"}))
+		Expect(left.Select("by_country", "avg_price")).To(BeAssignableToTypeOf(&aggretastic.AvgAggregation{}))
+	})
+
+	It("should keep the right on conflict", func() {
+		_, err := left.Merge(right, aggretastic.MergeKeepRight)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(left.Select("by_country").GetSubNames()).To(Equal([]string{"revenue", "avg_price", "customers", "x"}))
+		Expect(left.Select("by_country", "avg_price")).To(BeAssignableToTypeOf(&aggretastic.SumAggregation{}))
+	})
+
+	It("should fail on conflict without changes", func() {
+		report, err := left.Merge(right, aggretastic.MergeErrorOnConflict)
+		Expect(errors.Is(err, aggretastic.ErrMergeConflict)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("by_country>avg_price"))
+		Expect(report.Conflicts).To(HaveLen(1))
+		Expect(left).To(HaveLen(1))
+		Expect(left.Select("by_country").GetSubNames()).To(Equal([]string{"revenue", "avg_price"}))
+	})
+
+	It("should rename on conflict and rewrite buckets paths", func() {
+		report, err := left.Merge(right, aggretastic.MergeRenameOnConflict)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Conflicts[0].RenamedTo).To(Equal("avg_price_2"))
+
+		Expect(left.Select("by_country").GetSubNames()).To(Equal([]string{"revenue", "avg_price", "avg_price_2", "customers", "x"}))
+		src, _ := left.Select("by_country", "x").Source()
+		Expect(src).To(HaveKeyWithValue("max_bucket", HaveKeyWithValue("buckets_path", "avg_price_2")))
+
+		// src is not changed
+		Expect(right.Select("by_country").GetSubNames()).To(ContainElement("avg_price"))
+	})
+
+	It("should merge subAggregations of trees", func() {
+		report, err := aggretastic.Merge(left["by_country"], right["by_country"], aggretastic.MergeKeepLeft)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Added).To(Equal([][]string{{"customers"}, {"x"}}))
+	})
+})
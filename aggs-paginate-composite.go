@@ -0,0 +1,149 @@
+package aggretastic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/olivere/elastic/v7"
+)
+
+var ErrAggIsNotComposite = fmt.Errorf("agg is not composite")
+
+// SearchFunc runs the search request with the aggregations and returns its result.
+// It makes the library independent from the client, e.g.
+//
+//	func(ctx context.Context, aggs aggretastic.Aggregations) (*elastic.SearchResult, error) {
+//		search := client.Search(index).Size(0)
+//		for name, agg := range aggs {
+//			search = search.Aggregation(name, agg)
+//		}
+//		return search.Do(ctx)
+//	}
+type SearchFunc func(ctx context.Context, aggs Aggregations) (*elastic.SearchResult, error)
+
+// CompositePages iterates the pages of composite aggregation.
+// It's used like bufio.Scanner:
+//
+//	pages, err := aggs.PaginateComposite(ctx, search, "pages")
+//	for pages.Next() {
+//		for _, bucket := range pages.Buckets() { ... }
+//	}
+//	if err := pages.Err(); err != nil { ... }
+type CompositePages struct {
+	ctx       context.Context
+	search    SearchFunc
+	path      []string
+	aggs      Aggregations
+	composite *CompositeAggregation
+
+	result *elastic.SearchResult
+	page   *elastic.AggregationBucketCompositeItems
+	done   bool
+	err    error
+}
+
+// PaginateComposite returns the iterator over the pages of composite aggregation located by path.
+// The aggregations are cloned so the iterator doesn't change them: `after` is set on the clone only.
+// Every page is requested with the search function; the iteration stops when a page has no buckets or no after_key
+func (a *Aggregations) PaginateComposite(ctx context.Context, search SearchFunc, path ...string) (*CompositePages, error) {
+	if len(path) == 0 {
+		return nil, &PathError{Op: "PaginateComposite", Err: ErrNoPath}
+	}
+
+	aggs := a.Clone()
+	agg := aggs.Select(path...)
+	if agg == nil {
+		return nil, &PathError{Op: "PaginateComposite", Path: missingPath(a, path), Err: ErrSubAggNotFound}
+	}
+
+	composite, ok := agg.(*CompositeAggregation)
+	if !ok {
+		return nil, &PathError{Op: "PaginateComposite", Path: joinPath(path), Node: agg, Err: ErrAggIsNotComposite}
+	}
+
+	return &CompositePages{
+		ctx:       ctx,
+		search:    search,
+		path:      joinPath(path),
+		aggs:      aggs,
+		composite: composite,
+	}, nil
+}
+
+// Next requests the next page. It returns false when there are no more pages or an error happened
+func (p *CompositePages) Next() bool {
+	if p.done {
+		return false
+	}
+
+	if err := p.ctx.Err(); err != nil {
+		return p.fail(err)
+	}
+
+	if p.page != nil {
+		p.composite.AggregateAfter(p.page.AfterKey)
+	}
+
+	res, err := p.search(p.ctx, p.aggs)
+	if err != nil {
+		return p.fail(err)
+	}
+
+	results, err := p.aggs.SelectResult(res, p.path...)
+	if err != nil {
+		return p.fail(withPathPrefix("PaginateComposite", nil, err))
+	}
+	if len(results) != 1 {
+		return p.fail(&PathError{Op: "PaginateComposite", Path: p.path, Node: p.composite, Err: fmt.Errorf("%w: %d composite results", ErrPathNotSelectable, len(results))})
+	}
+
+	page, ok := results[0].Value.(*elastic.AggregationBucketCompositeItems)
+	if !ok {
+		return p.fail(&PathError{Op: "PaginateComposite", Path: p.path, Node: p.composite, Err: ErrResultNotFound})
+	}
+
+	if len(page.Buckets) == 0 {
+		// there is no current page: Buckets(), AfterKey() and Result() are nil
+		p.result, p.page = nil, nil
+		p.done = true
+		return false
+	}
+
+	p.result, p.page = res, page
+	// no after_key means the last page
+	p.done = len(page.AfterKey) == 0
+
+	return true
+}
+
+func (p *CompositePages) fail(err error) bool {
+	p.err = err
+	p.done = true
+	return false
+}
+
+// Buckets returns the buckets of the current page
+func (p *CompositePages) Buckets() []*elastic.AggregationBucketCompositeItem {
+	if p.page == nil {
+		return nil
+	}
+	return p.page.Buckets
+}
+
+// AfterKey returns the after_key of the current page. It can be used to resume the pagination later
+func (p *CompositePages) AfterKey() map[string]interface{} {
+	if p.page == nil {
+		return nil
+	}
+	return p.page.AfterKey
+}
+
+// Result returns the whole search result of the current page
+func (p *CompositePages) Result() *elastic.SearchResult {
+	return p.result
+}
+
+// Err returns the error which stopped the iteration
+func (p *CompositePages) Err() error {
+	return p.err
+}
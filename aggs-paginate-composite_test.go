@@ -0,0 +1,123 @@
+package aggretastic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aahainc/aggretastic"
+	"github.com/olivere/elastic/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PaginateComposite", func() {
+
+	var aggs aggretastic.Aggregations
+	var requestedAfter []interface{}
+
+	// fakeSearch returns a page of two countries after the requested one
+	countries := []string{"de", "es", "fr", "it", "nl"}
+	fakeSearch := func(ctx context.Context, request aggretastic.Aggregations) (*elastic.SearchResult, error) {
+		src, err := request.Select("sold", "pages").Source()
+		if err != nil {
+			return nil, err
+		}
+		after := src.(map[string]interface{})["composite"].(map[string]interface{})["after"]
+		requestedAfter = append(requestedAfter, after)
+
+		from := 0
+		if after != nil {
+			for i, country := range countries {
+				if country == after.(map[string]interface{})["country"] {
+					from = i + 1
+				}
+			}
+		}
+
+		buckets := make([]map[string]interface{}, 0)
+		for i := from; i < len(countries) && i < from+2; i++ {
+			buckets = append(buckets, map[string]interface{}{"key": map[string]interface{}{"country": countries[i]}, "doc_count": 1})
+		}
+		page := map[string]interface{}{"buckets": buckets}
+		if len(buckets) > 0 {
+			page["after_key"] = buckets[len(buckets)-1]["key"]
+		}
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"aggregations": map[string]interface{}{"sold": map[string]interface{}{"doc_count": 5, "pages": page}},
+		})
+		res := &elastic.SearchResult{}
+		return res, json.Unmarshal(body, res)
+	}
+
+	BeforeEach(func() {
+		requestedAfter = nil
+
+		sold := aggretastic.NewFilterAggregation().Filter(elastic.NewTermQuery("sold", true))
+		sold.Inject(aggretastic.NewCompositeAggregation().Size(2).Sources(
+			aggretastic.NewCompositeAggregationTermsValuesSource("country").Field("country"),
+		), "pages")
+		aggs = aggretastic.Aggregations{"sold": sold}
+	})
+
+	It("should go through all the pages", func() {
+		pages, err := aggs.PaginateComposite(context.Background(), fakeSearch, "sold", "pages")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		keys := make([]interface{}, 0)
+		for pages.Next() {
+			for _, bucket := range pages.Buckets() {
+				keys = append(keys, bucket.Key["country"])
+			}
+		}
+		Expect(pages.Err()).ShouldNot(HaveOccurred())
+		Expect(keys).To(Equal([]interface{}{"de", "es", "fr", "it", "nl"}))
+		Expect(requestedAfter).To(HaveLen(4))
+		Expect(requestedAfter[0]).To(BeNil())
+		Expect(requestedAfter[3]).To(Equal(map[string]interface{}{"country": "nl"}))
+
+		// the last page is empty
+		Expect(pages.Buckets()).To(BeNil())
+		Expect(pages.AfterKey()).To(BeNil())
+		Expect(pages.Result()).To(BeNil())
+
+		src, _ := aggs.Select("sold", "pages").Source()
+		Expect(src.(map[string]interface{})["composite"]).NotTo(HaveKey("after"))
+	})
+
+	It("should stop on search error", func() {
+		failure := fmt.Errorf("boom")
+		pages, err := aggs.PaginateComposite(context.Background(), func(ctx context.Context, aggs aggretastic.Aggregations) (*elastic.SearchResult, error) {
+			return nil, failure
+		}, "sold", "pages")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(pages.Next()).To(BeFalse())
+		Expect(pages.Err()).To(Equal(failure))
+	})
+
+	It("should stop when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		pages, err := aggs.PaginateComposite(ctx, fakeSearch, "sold", "pages")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(pages.Next()).To(BeTrue())
+		cancel()
+		Expect(pages.Next()).To(BeFalse())
+		Expect(pages.Err()).To(Equal(context.Canceled))
+	})
+
+	It("should check the path", func() {
+		_, err := aggs.PaginateComposite(context.Background(), fakeSearch, "sold")
+		Expect(errors.Is(err, aggretastic.ErrAggIsNotComposite)).To(BeTrue())
+
+		_, err = aggs.PaginateComposite(context.Background(), fakeSearch, "sold", "x")
+		Expect(errors.Is(err, aggretastic.ErrSubAggNotFound)).To(BeTrue())
+
+		var pathErr *aggretastic.PathError
+		Expect(errors.As(err, &pathErr)).To(BeTrue())
+		Expect(pathErr.Op).To(Equal("PaginateComposite"))
+		Expect(pathErr.Path).To(Equal([]string{"sold", "x"}))
+	})
+})
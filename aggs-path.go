@@ -0,0 +1,142 @@
+package aggretastic
+
+import (
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidPath = fmt.Errorf("invalid path")
+
+const (
+	// PathSeparator separates the names of aggregations in path expression, the same way as in buckets_path
+	PathSeparator = '>'
+
+	// PathEscape escapes the separator (and itself) in the names of aggregations
+	PathEscape = '\\'
+)
+
+// ParsePath splits the path expression like "by_country>by_day>revenue" into the names of aggregations.
+// The separator and the backslash are escaped with the backslash: `a\>b>c` is ["a>b", "c"]
+func ParsePath(expr string) ([]string, error) {
+	if expr == "" {
+		return nil, ErrNoPath
+	}
+
+	path := make([]string, 0, strings.Count(expr, string(PathSeparator))+1)
+	name := strings.Builder{}
+	escaped := false
+
+	for i, r := range expr {
+		switch {
+		case escaped:
+			if r != PathSeparator && r != PathEscape {
+				return nil, fmt.Errorf("%w: unexpected escaped %q at %d of %q", ErrInvalidPath, r, i, expr)
+			}
+			name.WriteRune(r)
+			escaped = false
+		case r == PathEscape:
+			escaped = true
+		case r == PathSeparator:
+			if name.Len() == 0 {
+				return nil, fmt.Errorf("%w: empty name at %d of %q", ErrInvalidPath, i, expr)
+			}
+			path = append(path, name.String())
+			name.Reset()
+		default:
+			name.WriteRune(r)
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("%w: unterminated escape in %q", ErrInvalidPath, expr)
+	}
+	if name.Len() == 0 {
+		return nil, fmt.Errorf("%w: empty name at %d of %q", ErrInvalidPath, len(expr), expr)
+	}
+
+	return append(path, name.String()), nil
+}
+
+// FormatPath joins the names of aggregations into the path expression escaping them. It's the reverse of ParsePath()
+func FormatPath(path []string) string {
+	escaper := strings.NewReplacer(string(PathEscape), `\\`, string(PathSeparator), `\>`)
+
+	names := make([]string, len(path))
+	for i, name := range path {
+		names[i] = escaper.Replace(name)
+	}
+	return strings.Join(names, string(PathSeparator))
+}
+
+// SelectPath returns any subAgg by its path expression. See ParsePath()
+func (a *tree) SelectPath(expr string) Aggregation {
+	path, err := ParsePath(expr)
+	if err != nil {
+		return nil
+	}
+	return a.Select(path...)
+}
+
+// PopPath returns a subAgg by its path expression and removes it from tree. See ParsePath()
+func (a *tree) PopPath(expr string) Aggregation {
+	path, err := ParsePath(expr)
+	if err != nil {
+		return nil
+	}
+	return a.Pop(path...)
+}
+
+// InjectPath sets new subAgg by its path expression. See ParsePath()
+func (a *tree) InjectPath(subAgg Aggregation, expr string) (resultPaths [][]string, err error) {
+	path, err := ParsePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	if resultPaths, err = a.Inject(subAgg, path...); err != nil {
+		err = withPathPrefix("InjectPath", nil, err)
+	}
+	return
+}
+
+func (a *notInjectable) SelectPath(expr string) Aggregation {
+	return nil
+}
+
+func (a *notInjectable) PopPath(expr string) Aggregation {
+	return nil
+}
+
+func (a *notInjectable) InjectPath(subAgg Aggregation, expr string) (resultPaths [][]string, err error) {
+	err = a.notInjectableError("InjectPath")
+	return
+}
+
+// SelectPath selects an aggregation from the map by its path expression. See ParsePath()
+func (a *Aggregations) SelectPath(expr string) Aggregation {
+	path, err := ParsePath(expr)
+	if err != nil {
+		return nil
+	}
+	return a.Select(path...)
+}
+
+// PopPath pops an aggregation from the map by its path expression. See ParsePath()
+func (a *Aggregations) PopPath(expr string) Aggregation {
+	path, err := ParsePath(expr)
+	if err != nil {
+		return nil
+	}
+	return a.Pop(path...)
+}
+
+// InjectPath injects an aggregation into the map by its path expression. See ParsePath()
+func (a *Aggregations) InjectPath(subAgg Aggregation, expr string) (resultPaths [][]string, err error) {
+	path, err := ParsePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	if resultPaths, err = a.Inject(subAgg, path...); err != nil {
+		err = withPathPrefix("InjectPath", nil, err)
+	}
+	return
+}
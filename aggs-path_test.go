@@ -0,0 +1,59 @@
+package aggretastic_test
+
+import (
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path expression", func() {
+
+	It("should parse and format paths", func() {
+		path, err := aggretastic.ParsePath("by_country>by_day>revenue")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal([]string{"by_country", "by_day", "revenue"}))
+
+		path, err = aggretastic.ParsePath(`a\>b>c\\d`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal([]string{"a>b", `c\d`}))
+		Expect(aggretastic.FormatPath(path)).To(Equal(`a\>b>c\\d`))
+
+		_, err = aggretastic.ParsePath("")
+		Expect(err).To(MatchError(aggretastic.ErrNoPath))
+
+		for _, expr := range []string{">a", "a>", "a>>b", `a\b`, `a\`} {
+			_, err = aggretastic.ParsePath(expr)
+			Expect(err).To(MatchError(aggretastic.ErrInvalidPath), expr)
+		}
+	})
+
+	It("should select, inject and pop by path expression", func() {
+		agg := aggretastic.NewTermsAggregation().Field("country")
+		agg.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+
+		paths, err := agg.InjectPath(aggretastic.NewSumAggregation().Field("price"), `by_day>revenue\>0`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(Equal([][]string{{"by_day", "revenue>0"}}))
+
+		Expect(agg.SelectPath(`by_day>revenue\>0`)).To(BeAssignableToTypeOf(&aggretastic.SumAggregation{}))
+		Expect(agg.SelectPath("by_day>>revenue")).To(BeNil())
+
+		_, err = agg.InjectPath(aggretastic.NewSumAggregation(), "by_day>")
+		Expect(err).To(MatchError(aggretastic.ErrInvalidPath))
+
+		Expect(agg.PopPath(`by_day>revenue\>0`)).NotTo(BeNil())
+		Expect(agg.Select("by_day", "revenue>0")).To(BeNil())
+	})
+
+	It("should select, inject and pop aggregations of the map by path expression", func() {
+		aggs := aggretastic.Aggregations{
+			"by_country": aggretastic.NewTermsAggregation().Field("country"),
+		}
+
+		_, err := aggs.InjectPath(aggretastic.NewSumAggregation().Field("price"), "by_country>revenue")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(aggs.SelectPath("by_country>revenue")).NotTo(BeNil())
+		Expect(aggs.PopPath("by_country>revenue")).NotTo(BeNil())
+		Expect(aggs.SelectPath("by_country>revenue")).To(BeNil())
+	})
+})
@@ -0,0 +1,103 @@
+package aggretastic
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrTemplateNotFound = fmt.Errorf("template is not found")
+
+// Registry is a concurrency-safe catalog of named aggregation templates.
+// The stored templates are never mutated: every write replaces a template with a new version.
+// The snapshots are not copy-on-write: Snapshot eagerly deep clones the whole template on every call,
+// so Inject/Pop on a snapshot never touch the shared template
+type Registry struct {
+	// mu guards the map of templates, the templates themselves are immutable
+	mu        sync.RWMutex
+	templates map[string]Aggregations
+
+	// updateMu serializes the updates so they don't override each other
+	updateMu sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{templates: make(map[string]Aggregations)}
+}
+
+// Set stores the copy of aggregations as the template
+func (r *Registry) Set(name string, aggs Aggregations) {
+	template := aggs.Clone()
+	if template == nil {
+		template = make(Aggregations)
+	}
+
+	r.updateMu.Lock()
+	defer r.updateMu.Unlock()
+	r.store(name, template)
+}
+
+// Snapshot returns the deep copy of template which is safe to change. Every call clones the whole template
+func (r *Registry) Snapshot(name string) (Aggregations, error) {
+	template, ok := r.load(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return template.Clone(), nil
+}
+
+// Update atomically changes the template: fn changes the copy of template which replaces it when fn succeeds.
+// The readers see either the old or the new version of template. An absent template is created from the empty one
+func (r *Registry) Update(name string, fn func(aggs Aggregations) error) error {
+	r.updateMu.Lock()
+	defer r.updateMu.Unlock()
+
+	draft := make(Aggregations)
+	if template, ok := r.load(name); ok {
+		draft = template.Clone()
+	}
+	if err := fn(draft); err != nil {
+		return err
+	}
+
+	r.store(name, draft)
+	return nil
+}
+
+// Delete removes the template
+func (r *Registry) Delete(name string) {
+	r.updateMu.Lock()
+	defer r.updateMu.Unlock()
+
+	r.mu.Lock()
+	delete(r.templates, name)
+	r.mu.Unlock()
+}
+
+// Names returns the sorted names of templates
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) load(name string) (Aggregations, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	template, ok := r.templates[name]
+	return template, ok
+}
+
+func (r *Registry) store(name string, template Aggregations) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.templates[name] = template
+}
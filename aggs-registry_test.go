@@ -0,0 +1,123 @@
+package aggretastic_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+
+	var registry *aggretastic.Registry
+
+	BeforeEach(func() {
+		byCountry := aggretastic.NewTermsAggregation().Field("country")
+		byCountry.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at").CalendarInterval("1d"), "by_day")
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "revenue")
+
+		registry = aggretastic.NewRegistry()
+		registry.Set("sales", aggretastic.Aggregations{"by_country": byCountry})
+	})
+
+	It("should hand out snapshots which don't touch the template", func() {
+		snapshot, err := registry.Snapshot("sales")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = snapshot.Inject(aggretastic.NewAvgAggregation().Field("price"), "by_country", "by_day", "avg_price")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshot.Pop("by_country", "by_day", "revenue")).NotTo(BeNil())
+
+		template, _ := registry.Snapshot("sales")
+		Expect(template.Select("by_country", "by_day").GetSubNames()).To(Equal([]string{"revenue"}))
+
+		_, err = registry.Snapshot("unknown")
+		Expect(errors.Is(err, aggretastic.ErrTemplateNotFound)).To(BeTrue())
+	})
+
+	It("should update the template atomically", func() {
+		err := registry.Update("sales", func(aggs aggretastic.Aggregations) error {
+			_, err := aggs.Inject(aggretastic.NewAvgAggregation().Field("price"), "by_country", "by_day", "avg_price")
+			Expect(err).NotTo(HaveOccurred())
+			return fmt.Errorf("rejected")
+		})
+		Expect(err).To(MatchError("rejected"))
+
+		template, _ := registry.Snapshot("sales")
+		Expect(template.Select("by_country", "by_day", "avg_price")).To(BeNil())
+
+		Expect(registry.Update("sales", func(aggs aggretastic.Aggregations) error {
+			_, err := aggs.Inject(aggretastic.NewAvgAggregation().Field("price"), "by_country", "by_day", "avg_price")
+			return err
+		})).To(Succeed())
+
+		template, _ = registry.Snapshot("sales")
+		Expect(template.Select("by_country", "by_day", "avg_price")).NotTo(BeNil())
+		Expect(registry.Names()).To(Equal([]string{"sales"}))
+
+		registry.Delete("sales")
+		Expect(registry.Names()).To(BeEmpty())
+	})
+
+	// run with `go test -race` to detect the data races
+	It("should be safe for concurrent use", func() {
+		const workers, iterations = 8, 200
+
+		errs := make(chan error, workers*iterations+iterations)
+		wg := sync.WaitGroup{}
+
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < iterations; i++ {
+					snapshot, err := registry.Snapshot("sales")
+					if err != nil {
+						errs <- err
+						return
+					}
+
+					name := fmt.Sprintf("request_%d_%d", w, i)
+					if _, err := snapshot.Inject(aggretastic.NewAvgAggregation().Field("price"), "by_country", "by_day", name); err != nil {
+						errs <- err
+						return
+					}
+					snapshot.Pop("by_country", "by_day", "revenue")
+					if _, err := snapshot.Select("by_country").Source(); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(w)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				err := registry.Update("sales", func(aggs aggretastic.Aggregations) error {
+					aggs.Select("by_country").(*aggretastic.TermsAggregation).Size(i + 1)
+					return nil
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		template, _ := registry.Snapshot("sales")
+		Expect(template.Select("by_country", "by_day").GetSubNames()).To(Equal([]string{"revenue"}))
+		src, _ := template.Select("by_country").Source()
+		Expect(src).To(HaveKeyWithValue("terms", HaveKeyWithValue("size", iterations)))
+	})
+})
@@ -0,0 +1,293 @@
+package aggretastic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rename changes the name of subAgg located by path keeping its position.
+// Every buckets_path of pipelines in the tree which refers the renamed aggregation is rewritten
+func Rename(agg Aggregation, path []string, newName string) error {
+	return newRestructure(agg, func(fn WalkFunc) error { return Walk(agg, fn) }).rename(path, newName)
+}
+
+// Move moves subAgg from one path to another one (the last element of `to` is the new name).
+// Every buckets_path of pipelines in the tree which refers the moved aggregation (or is placed in it) is rewritten.
+// The move is not done if any buckets_path can't be expressed after it
+func Move(agg Aggregation, from, to []string) error {
+	return newRestructure(agg, func(fn WalkFunc) error { return Walk(agg, fn) }).move(from, to)
+}
+
+// Rename changes the name of aggregation located by path. See Rename()
+func (a *Aggregations) Rename(path []string, newName string) error {
+	return newRestructure(a, a.Walk).rename(path, newName)
+}
+
+// Move moves aggregation from one path to another one. See Move()
+func (a *Aggregations) Move(from, to []string) error {
+	return newRestructure(a, a.Walk).move(from, to)
+}
+
+// restructureRoot is the root of restructured tree: Aggregation or *Aggregations
+type restructureRoot interface {
+	Select(path ...string) Aggregation
+	Pop(path ...string) Aggregation
+	Inject(subAgg Aggregation, path ...string) (resultPaths [][]string, err error)
+}
+
+type restructure struct {
+	root restructureRoot
+	walk func(fn WalkFunc) error
+}
+
+func newRestructure(root restructureRoot, walk func(fn WalkFunc) error) *restructure {
+	return &restructure{root: root, walk: walk}
+}
+
+// subAggregationRenamer is implemented by every aggregation with *tree
+type subAggregationRenamer interface {
+	renameSubAggregation(name, newName string) error
+}
+
+func (r *restructure) rename(path []string, newName string) error {
+	if len(path) == 0 {
+		return &PathError{Op: "Rename", Err: ErrNoPath}
+	}
+	if r.root.Select(path...) == nil {
+		return &PathError{Op: "Rename", Path: missingPath(r.root, path), Err: ErrSubAggNotFound}
+	}
+
+	to := joinPath(path[:len(path)-1], newName)
+	rewrites, err := r.bucketsPathsRewrites(newMoveRelocation(path, to))
+	if err != nil {
+		return err
+	}
+
+	var parent interface{} = r.root
+	if len(path) > 1 {
+		parent = r.root.Select(path[:len(path)-1]...)
+	}
+
+	switch p := parent.(type) {
+	case subAggregationRenamer:
+		err = p.renameSubAggregation(path[len(path)-1], newName)
+	case *Aggregations:
+		err = p.renameAggregation(path[0], newName)
+	default:
+		err = ErrPathNotSelectable
+	}
+	if err != nil {
+		return &PathError{Op: "Rename", Path: to, Err: err}
+	}
+
+	rewrites.apply()
+	return nil
+}
+
+func (r *restructure) move(from, to []string) error {
+	if len(from) == 0 || len(to) == 0 {
+		return &PathError{Op: "Move", Err: ErrNoPath}
+	}
+	if r.root.Select(from...) == nil {
+		return &PathError{Op: "Move", Path: missingPath(r.root, from), Err: ErrSubAggNotFound}
+	}
+	if existing := r.root.Select(to...); existing != nil {
+		return &PathError{Op: "Move", Path: to, Node: existing, Err: ErrSubAggExists}
+	}
+	if hasPathPrefix(to, from) {
+		// the aggregation can't be moved into itself
+		return &PathError{Op: "Move", Path: to, Err: ErrPathNotSelectable}
+	}
+	if len(to) > 1 {
+		if parent := r.root.Select(to[:len(to)-1]...); parent == nil || !isBucketAggregation(parent) {
+			return &PathError{Op: "Move", Path: to[:len(to)-1], Node: parent, Err: ErrAggIsNotInjectable}
+		}
+	}
+
+	rewrites, err := r.bucketsPathsRewrites(newMoveRelocation(from, to))
+	if err != nil {
+		return err
+	}
+
+	parent, next := r.nextSibling(from)
+	agg := r.root.Pop(from...)
+	if _, err := r.root.Inject(agg, to...); err != nil {
+		// put it back to the same position
+		r.root.Inject(agg, from...)
+		if next != "" {
+			parent.MoveBefore(from[len(from)-1], next)
+		}
+		return withPathPrefix("Move", nil, err)
+	}
+
+	rewrites.apply()
+	return nil
+}
+
+// orderedParent is the parent which keeps the order of subAggregations
+type orderedParent interface {
+	GetSubNames() []string
+	MoveBefore(name, mark string) error
+}
+
+// nextSibling returns the parent of aggregation located by path and the name of the next subAgg after it.
+// The name is empty if the aggregation is the last one or the parent has no order
+func (r *restructure) nextSibling(path []string) (orderedParent, string) {
+	var parent interface{} = r.root
+	if len(path) > 1 {
+		parent = r.root.Select(path[:len(path)-1]...)
+	}
+
+	p, ok := parent.(orderedParent)
+	if !ok {
+		return nil, ""
+	}
+
+	names := p.GetSubNames()
+	if i := indexOfName(names, path[len(path)-1]); i >= 0 && i+1 < len(names) {
+		return p, names[i+1]
+	}
+	return p, ""
+}
+
+// bucketsPathsRewrite is the new buckets paths of a pipeline
+type bucketsPathsRewrite struct {
+	pipeline Aggregation
+	paths    []string
+}
+
+type bucketsPathsRewrites []bucketsPathsRewrite
+
+func (rewrites bucketsPathsRewrites) apply() {
+	for _, rewrite := range rewrites {
+		setBucketsPaths(rewrite.pipeline, rewrite.paths)
+	}
+}
+
+// relocation describes how the aggregations change their paths after restructuring
+type relocation struct {
+	// path returns the new path of aggregation
+	path func(path []string) []string
+
+	// chain returns the new absolute chain of buckets path
+	chain func(chain []BucketsPathElement) []BucketsPathElement
+
+	// description is used in errors e.g. "moving a>b to c"
+	description string
+}
+
+func newMoveRelocation(from, to []string) relocation {
+	return relocation{
+		path:        func(path []string) []string { return movedPath(path, from, to) },
+		chain:       func(chain []BucketsPathElement) []BucketsPathElement { return movedChain(chain, from, to) },
+		description: fmt.Sprintf("moving %s to %s", strings.Join(from, ">"), strings.Join(to, ">")),
+	}
+}
+
+// bucketsPathsRewrites computes buckets paths of all the pipelines after the aggregations are relocated.
+// The paths are relative to the parents of pipelines so they are changed when:
+//   - the path goes through the relocated aggregation;
+//   - the pipeline itself is relocated (as a part of relocated aggregation).
+func (r *restructure) bucketsPathsRewrites(reloc relocation) (bucketsPathsRewrites, error) {
+	rewrites := make(bucketsPathsRewrites, 0)
+
+	err := r.walk(func(pipelinePath []string, agg Aggregation) error {
+		paths := bucketsPathsOf(agg)
+		if len(paths) == 0 || len(pipelinePath) == 0 {
+			return nil
+		}
+
+		parent := pipelinePath[:len(pipelinePath)-1]
+		newParent := reloc.path(pipelinePath)
+		newParent = newParent[:len(newParent)-1]
+
+		changed := false
+		newPaths := make([]string, len(paths))
+		for i, path := range paths {
+			newPaths[i] = path
+
+			bp, err := ParseBucketsPath(path)
+			if err != nil || bp.IsSpecial() {
+				// nothing to rewrite
+				continue
+			}
+
+			// absolute chain of the path
+			chain := make([]BucketsPathElement, 0, len(parent)+len(bp.Elements))
+			for _, name := range parent {
+				chain = append(chain, BucketsPathElement{Name: name})
+			}
+			chain = append(chain, bp.Elements...)
+
+			newChain := reloc.chain(chain)
+			if !hasPathPrefix(chainNames(newChain), newParent) || len(newChain) == len(newParent) {
+				return fmt.Errorf("%w: %q of %s can't be rewritten after %s", ErrInvalidBucketsPath,
+					path, strings.Join(pipelinePath, ">"), reloc.description)
+			}
+
+			newBP := &ParsedBucketsPath{
+				Elements:        newChain[len(newParent):],
+				Property:        bp.Property,
+				bracketProperty: bp.bracketProperty,
+			}
+			if newPaths[i] = newBP.String(); newPaths[i] != path {
+				changed = true
+			}
+		}
+
+		if changed {
+			rewrites = append(rewrites, bucketsPathsRewrite{pipeline: agg, paths: newPaths})
+		}
+		return nil
+	})
+
+	return rewrites, err
+}
+
+// movedPath returns the path after the aggregation is moved from one path to another
+func movedPath(path, from, to []string) []string {
+	if !hasPathPrefix(path, from) {
+		return joinPath(path)
+	}
+	return joinPath(to, path[len(from):]...)
+}
+
+// movedChain returns the buckets path chain after the aggregation is moved from one path to another.
+// The key of the moved aggregation is kept
+func movedChain(chain []BucketsPathElement, from, to []string) []BucketsPathElement {
+	if !hasPathPrefix(chainNames(chain), from) {
+		return chain
+	}
+
+	moved := make([]BucketsPathElement, 0, len(to)+len(chain)-len(from))
+	for _, name := range to[:len(to)-1] {
+		moved = append(moved, BucketsPathElement{Name: name})
+	}
+
+	self := chain[len(from)-1]
+	self.Name = to[len(to)-1]
+	moved = append(moved, self)
+
+	return append(moved, chain[len(from):]...)
+}
+
+func chainNames(chain []BucketsPathElement) []string {
+	names := make([]string, len(chain))
+	for i := range chain {
+		names[i] = chain[i].Name
+	}
+	return names
+}
+
+// hasPathPrefix checks if the path starts with the prefix
+func hasPathPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package aggretastic_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aahainc/aggretastic"
+	"github.com/olivere/elastic/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// failingInjectAggregation can't inject the subAggregations named "broken"
+type failingInjectAggregation struct {
+	*aggretastic.TermsAggregation
+}
+
+func (a failingInjectAggregation) Inject(subAgg aggretastic.Aggregation, path ...string) ([][]string, error) {
+	if len(path) > 0 && path[len(path)-1] == "broken" {
+		return nil, fmt.Errorf("can't inject")
+	}
+	return a.TermsAggregation.Inject(subAgg, path...)
+}
+
+var _ = Describe("Rename and Move", func() {
+
+	var aggs aggretastic.Aggregations
+
+	sourceOf := func(path ...string) string {
+		src, err := aggs.Select(path...).Source()
+		Expect(err).ShouldNot(HaveOccurred())
+		b, err := json.Marshal(src)
+		Expect(err).ShouldNot(HaveOccurred())
+		return string(b)
+	}
+
+	BeforeEach(func() {
+		byCountry := aggretastic.NewTermsAggregation().Field("country")
+		byCountry.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "revenue")
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("cost"), "by_day", "cost")
+		byCountry.Inject(aggretastic.NewStatsAggregation().Field("price"), "by_day", "price")
+		byCountry.Inject(aggretastic.NewBucketScriptAggregation().
+			AddBucketsPath("a", "revenue").AddBucketsPath("b", "cost").
+			Script(elastic.NewScript("params.a - params.b")), "by_day", "margin")
+		byCountry.Inject(aggretastic.NewDerivativeAggregation().BucketsPath("revenue"), "by_day", "growth")
+		byCountry.Inject(aggretastic.NewBucketSelectorAggregation().
+			AddBucketsPath("avg", "price.avg").Script(elastic.NewScript("params.avg > 10")), "by_day", "expensive")
+		byCountry.Inject(aggretastic.NewBucketSortAggregation().Sort("revenue", false), "by_day", "top")
+		byCountry.Inject(aggretastic.NewMaxBucketAggregation().BucketsPath("by_day>revenue"), "best_day")
+		byCountry.Inject(aggretastic.NewStatsBucketAggregation().BucketsPath("by_day>price.max"), "day_prices")
+		aggs = aggretastic.Aggregations{"by_country": byCountry}
+	})
+
+	It("should rename and rewrite buckets paths", func() {
+		Expect(aggs.Rename([]string{"by_country", "by_day", "revenue"}, "sales")).To(Succeed())
+		Expect(aggs.Select("by_country", "by_day").GetSubNames()).To(Equal([]string{
+			"sales", "cost", "price", "margin", "growth", "expensive", "top",
+		}))
+
+		Expect(sourceOf("by_country", "by_day", "margin")).To(MatchJSON(`{"bucket_script":{"buckets_path":{"a":"sales","b":"cost"},"script":{"source":"params.a - params.b"}}}`))
+		Expect(sourceOf("by_country", "by_day", "growth")).To(MatchJSON(`{"derivative":{"buckets_path":"sales"}}`))
+		Expect(sourceOf("by_country", "by_day", "top")).To(MatchJSON(`{"bucket_sort":{"sort":[{"sales":{"order":"desc"}}]}}`))
+		Expect(sourceOf("by_country", "best_day")).To(MatchJSON(`{"max_bucket":{"buckets_path":"by_day>sales"}}`))
+		Expect(aggs.Validate()).To(Succeed())
+
+		Expect(aggs.Rename([]string{"by_country", "by_day"}, "daily")).To(Succeed())
+		Expect(sourceOf("by_country", "best_day")).To(MatchJSON(`{"max_bucket":{"buckets_path":"daily>sales"}}`))
+		Expect(sourceOf("by_country", "day_prices")).To(MatchJSON(`{"stats_bucket":{"buckets_path":"daily>price.max"}}`))
+		Expect(aggs.Validate()).To(Succeed())
+
+		Expect(aggs.Rename([]string{"by_country"}, "countries")).To(Succeed())
+		Expect(aggs).To(HaveKey("countries"))
+		Expect(aggs.Validate()).To(Succeed())
+	})
+
+	It("should move and rewrite buckets paths", func() {
+		aggs.Inject(aggretastic.NewFilterAggregation().Filter(elastic.NewTermQuery("paid", true)), "by_country", "by_day", "paid")
+
+		Expect(aggs.Move([]string{"by_country", "by_day", "price"}, []string{"by_country", "by_day", "paid", "paid_price"})).To(Succeed())
+		Expect(sourceOf("by_country", "by_day", "expensive")).To(MatchJSON(`{"bucket_selector":{"buckets_path":{"avg":"paid>paid_price.avg"},"script":{"source":"params.avg > 10"}}}`))
+		Expect(sourceOf("by_country", "day_prices")).To(MatchJSON(`{"stats_bucket":{"buckets_path":"by_day>paid>paid_price.max"}}`))
+		Expect(aggs.Validate()).To(Succeed())
+	})
+
+	It("should not move if buckets paths can't be rewritten", func() {
+		before := sourceOf("by_country")
+
+		err := aggs.Move([]string{"by_country", "by_day", "revenue"}, []string{"by_country", "revenue"})
+		Expect(errors.Is(err, aggretastic.ErrInvalidBucketsPath)).To(BeTrue())
+		Expect(sourceOf("by_country")).To(Equal(before))
+	})
+
+	It("should keep the position of aggregation if the move fails", func() {
+		root := failingInjectAggregation{aggs["by_country"].(*aggretastic.TermsAggregation)}
+		before := sourceOf("by_country")
+
+		Expect(aggretastic.Move(root, []string{"by_day", "cost"}, []string{"by_day", "broken"})).NotTo(Succeed())
+		Expect(sourceOf("by_country")).To(Equal(before))
+
+		Expect(aggretastic.Move(root, []string{"by_day"}, []string{"broken"})).NotTo(Succeed())
+		Expect(sourceOf("by_country")).To(Equal(before))
+	})
+
+	It("should check the paths", func() {
+		Expect(errors.Is(aggs.Rename([]string{"by_country", "x"}, "y"), aggretastic.ErrSubAggNotFound)).To(BeTrue())
+		Expect(errors.Is(aggs.Rename([]string{"by_country", "by_day", "revenue"}, "cost"), aggretastic.ErrSubAggExists)).To(BeTrue())
+		Expect(errors.Is(aggs.Move([]string{"by_country", "by_day"}, []string{"by_country", "by_day", "x"}), aggretastic.ErrPathNotSelectable)).To(BeTrue())
+		Expect(errors.Is(aggs.Move([]string{"by_country", "best_day"}, []string{"by_country", "by_day", "revenue", "x"}), aggretastic.ErrAggIsNotInjectable)).To(BeTrue())
+	})
+})
@@ -0,0 +1,322 @@
+package aggretastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/olivere/elastic/v7"
+)
+
+var ErrResultNotFound = fmt.Errorf("result is not found")
+
+// ResultBucket is a bucket of the parent aggregation the result belongs to
+type ResultBucket struct {
+	// Name is the name of the bucket aggregation
+	Name string
+
+	// Key is the key of the bucket: string, float64 or map for composite buckets.
+	// It's nil for single bucket aggregations (filter, nested etc.)
+	Key         interface{}
+	KeyAsString *string
+	DocCount    int64
+}
+
+// Result is a typed result of the aggregation for one combination of its parent buckets
+type Result struct {
+	// Buckets are the parent buckets of the result from the top to the bottom
+	Buckets []ResultBucket
+
+	// Aggregation is the requested aggregation the result belongs to
+	Aggregation Aggregation
+
+	// Value is the typed result of the aggregation, its type depends on the type of aggregation:
+	// *elastic.AggregationValueMetric for SumAggregation, *elastic.AggregationBucketKeyItems for TermsAggregation etc.
+	// The raw json.RawMessage is used for aggregations without a typed result
+	Value interface{}
+}
+
+// SelectResult resolves the path of requested aggregation in the search result.
+// It returns the typed result of the aggregation for every bucket of its parents
+// e.g. for the path "by_country", "revenue" it returns the value of "revenue" sum for every country.
+// The errors are *PathError
+func (a *Aggregations) SelectResult(res *elastic.SearchResult, path ...string) ([]*Result, error) {
+	if len(path) == 0 {
+		return nil, &PathError{Op: "SelectResult", Err: ErrNoPath}
+	}
+	if a == nil || res == nil || res.Aggregations == nil {
+		return nil, &PathError{Op: "SelectResult", Err: ErrResultNotFound}
+	}
+
+	scopes := []resultScope{{aggs: res.Aggregations}}
+	for i, name := range path {
+		agg := a.Select(path[:i+1]...)
+		if agg == nil {
+			return nil, &PathError{Op: "SelectResult", Path: joinPath(path[:i+1]), Err: ErrSubAggNotFound}
+		}
+
+		if i == len(path)-1 {
+			return scopesResults(scopes, name, agg, path)
+		}
+
+		next := make([]resultScope, 0, len(scopes))
+		for _, scope := range scopes {
+			raw, ok := scope.aggs[name]
+			if !ok {
+				return nil, &PathError{Op: "SelectResult", Path: joinPath(path[:i+1]), Node: agg, Err: ErrResultNotFound}
+			}
+
+			buckets, err := decodeResultBuckets(name, raw)
+			if err != nil {
+				return nil, &PathError{Op: "SelectResult", Path: joinPath(path[:i+1]), Node: agg, Err: err}
+			}
+
+			for _, bucket := range buckets {
+				next = append(next, resultScope{
+					buckets: append(append(make([]ResultBucket, 0, len(scope.buckets)+1), scope.buckets...), bucket.buckets...),
+					aggs:    bucket.aggs,
+				})
+			}
+		}
+		scopes = next
+	}
+
+	return nil, &PathError{Op: "SelectResult", Err: ErrNoPath}
+}
+
+// resultScope is a bucket of the response with the results of its subAggregations
+type resultScope struct {
+	buckets []ResultBucket
+	aggs    elastic.Aggregations
+}
+
+func scopesResults(scopes []resultScope, name string, agg Aggregation, path []string) ([]*Result, error) {
+	results := make([]*Result, 0, len(scopes))
+	for _, scope := range scopes {
+		value, ok := typedResult(scope.aggs, name, agg)
+		if !ok {
+			return nil, &PathError{Op: "SelectResult", Path: joinPath(path), Node: agg, Err: ErrResultNotFound}
+		}
+
+		results = append(results, &Result{
+			Buckets:     scope.buckets,
+			Aggregation: agg,
+			Value:       value,
+		})
+	}
+
+	return results, nil
+}
+
+// decodeResultBuckets returns the buckets of bucket aggregation result.
+// The result of single bucket aggregation is a bucket itself
+func decodeResultBuckets(name string, raw json.RawMessage) ([]resultScope, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+
+	rawBuckets, ok := obj["buckets"]
+	if !ok {
+		bucket, err := decodeResultBucket(name, raw)
+		if err != nil {
+			return nil, err
+		}
+		return []resultScope{{buckets: []ResultBucket{bucket}, aggs: obj}}, nil
+	}
+
+	var keys []string
+	var items []json.RawMessage
+	if trimmed := bytes.TrimSpace(rawBuckets); len(trimmed) > 0 && trimmed[0] == '{' {
+		// keyed buckets: named filters, keyed ranges etc.
+		var err error
+		if keys, items, err = decodeOrderedObject(trimmed); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(rawBuckets, &items); err != nil {
+		return nil, err
+	}
+
+	scopes := make([]resultScope, 0, len(items))
+	for i, item := range items {
+		bucket, err := decodeResultBucket(name, item)
+		if err != nil {
+			return nil, err
+		}
+		if bucket.Key == nil && keys != nil {
+			bucket.Key = keys[i]
+		}
+
+		var aggs elastic.Aggregations
+		if err := json.Unmarshal(item, &aggs); err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, resultScope{buckets: []ResultBucket{bucket}, aggs: aggs})
+	}
+
+	return scopes, nil
+}
+
+func decodeResultBucket(name string, raw json.RawMessage) (ResultBucket, error) {
+	var bucket struct {
+		Key         interface{} `json:"key"`
+		KeyAsString *string     `json:"key_as_string"`
+		DocCount    int64       `json:"doc_count"`
+	}
+	if err := json.Unmarshal(raw, &bucket); err != nil {
+		return ResultBucket{}, err
+	}
+
+	return ResultBucket{
+		Name:        name,
+		Key:         bucket.Key,
+		KeyAsString: bucket.KeyAsString,
+		DocCount:    bucket.DocCount,
+	}, nil
+}
+
+// decodeOrderedObject returns the keys and values of JSON object keeping their order
+func decodeOrderedObject(raw json.RawMessage) (keys []string, values []json.RawMessage, err error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err = dec.Token(); err != nil {
+		return
+	}
+
+	for dec.More() {
+		var token json.Token
+		if token, err = dec.Token(); err != nil {
+			return
+		}
+
+		var value json.RawMessage
+		if err = dec.Decode(&value); err != nil {
+			return
+		}
+
+		keys = append(keys, token.(string))
+		values = append(values, value)
+	}
+
+	return
+}
+
+// typedResult returns the result of aggregation `name` typed by the kind of requested aggregation
+func typedResult(aggs elastic.Aggregations, name string, agg Aggregation) (interface{}, bool) {
+	switch a := agg.(type) {
+	// metrics
+	case *AvgAggregation:
+		return aggs.Avg(name)
+	case *SumAggregation:
+		return aggs.Sum(name)
+	case *MinAggregation:
+		return aggs.Min(name)
+	case *MaxAggregation:
+		return aggs.Max(name)
+	case *ValueCountAggregation:
+		return aggs.ValueCount(name)
+	case *CardinalityAggregation:
+		return aggs.Cardinality(name)
+	case *WeightedAvgAggregation:
+		return aggs.WeightedAvg(name)
+	case *MedianAbsoluteDeviationAggregation:
+		return aggs.MedianAbsoluteDeviation(name)
+	case *StatsAggregation:
+		return aggs.Stats(name)
+	case *ExtendedStatsAggregation:
+		return aggs.ExtendedStats(name)
+	case *MatrixStatsAggregation:
+		return aggs.MatrixStats(name)
+	case *PercentilesAggregation:
+		return aggs.Percentiles(name)
+	case *PercentileRanksAggregation:
+		return aggs.PercentileRanks(name)
+	case *GeoBoundsAggregation:
+		return aggs.GeoBounds(name)
+	case *GeoCentroidAggregation:
+		return aggs.GeoCentroid(name)
+	case *ScriptedMetricAggregation:
+		return aggs.ScriptedMetric(name)
+
+	// buckets
+	case *TermsAggregation:
+		return aggs.Terms(name)
+	case *MultiTermsAggregation:
+		return aggs.Terms(name)
+	case *SignificantTermsAggregation:
+		return aggs.SignificantTerms(name)
+	case *SignificantTextAggregation:
+		return aggs.SignificantTerms(name)
+	case *HistogramAggregation:
+		return aggs.Histogram(name)
+	case *DateHistogramAggregation:
+		return aggs.DateHistogram(name)
+	case *RangeAggregation:
+		if a.keyed != nil && *a.keyed {
+			return aggs.KeyedRange(name)
+		}
+		return aggs.Range(name)
+	case *DateRangeAggregation:
+		if a.keyed != nil && *a.keyed {
+			return aggs.KeyedRange(name)
+		}
+		return aggs.DateRange(name)
+	case *IPRangeAggregation:
+		if a.keyed != nil && *a.keyed {
+			return aggs.KeyedRange(name)
+		}
+		return aggs.IPRange(name)
+	case *GeoDistanceAggregation:
+		return aggs.GeoDistance(name)
+	case *GeoHashGridAggregation:
+		return aggs.GeoHash(name)
+	case *CompositeAggregation:
+		return aggs.Composite(name)
+	case *FiltersAggregation:
+		return aggs.Filters(name)
+	case *AdjacencyMatrixAggregation:
+		return aggs.AdjacencyMatrix(name)
+	case *FilterAggregation:
+		return aggs.Filter(name)
+	case *GlobalAggregation:
+		return aggs.Global(name)
+	case *MissingAggregation:
+		return aggs.Missing(name)
+	case *NestedAggregation:
+		return aggs.Nested(name)
+	case *ReverseNestedAggregation:
+		return aggs.ReverseNested(name)
+	case *ChildrenAggregation:
+		return aggs.Children(name)
+	case *SamplerAggregation:
+		return aggs.Sampler(name)
+	case *DiversifiedSamplerAggregation:
+		return aggs.DiversifiedSampler(name)
+
+	// pipelines
+	case *AvgBucketAggregation:
+		return aggs.AvgBucket(name)
+	case *SumBucketAggregation:
+		return aggs.SumBucket(name)
+	case *MinBucketAggregation:
+		return aggs.MinBucket(name)
+	case *MaxBucketAggregation:
+		return aggs.MaxBucket(name)
+	case *StatsBucketAggregation:
+		return aggs.StatsBucket(name)
+	case *PercentilesBucketAggregation:
+		return aggs.PercentilesBucket(name)
+	case *DerivativeAggregation:
+		return aggs.Derivative(name)
+	case *CumulativeSumAggregation:
+		return aggs.CumulativeSum(name)
+	case *SerialDiffAggregation:
+		return aggs.SerialDiff(name)
+	case *MovAvgAggregation:
+		return aggs.MovAvg(name)
+	case *BucketScriptAggregation:
+		return aggs.BucketScript(name)
+	}
+
+	raw, ok := aggs[name]
+	return raw, ok
+}
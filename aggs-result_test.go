@@ -0,0 +1,104 @@
+package aggretastic_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aahainc/aggretastic"
+	"github.com/olivere/elastic/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelectResult", func() {
+
+	const response = `{
+		"aggregations": {
+			"by_country": {
+				"buckets": [
+					{"key": "de", "doc_count": 3, "revenue": {"value": 30}, "paid": {"doc_count": 2, "revenue": {"value": 20}}},
+					{"key": "fr", "doc_count": 1, "revenue": {"value": 10}, "paid": {"doc_count": 0, "revenue": {"value": 0}}}
+				]
+			},
+			"by_status": {
+				"buckets": {
+					"new": {"doc_count": 4, "price": {"count": 4, "min": 1, "max": 4, "avg": 2.5, "sum": 10}}
+				}
+			}
+		}
+	}`
+
+	var aggs aggretastic.Aggregations
+	var res *elastic.SearchResult
+
+	BeforeEach(func() {
+		byCountry := aggretastic.NewTermsAggregation().Field("country")
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "revenue")
+		byCountry.Inject(aggretastic.NewFilterAggregation().Filter(elastic.NewTermQuery("paid", true)), "paid")
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "paid", "revenue")
+
+		byStatus := aggretastic.NewFiltersAggregation().FilterWithName("new", elastic.NewTermQuery("status", "new"))
+		byStatus.Inject(aggretastic.NewStatsAggregation().Field("price"), "price")
+
+		aggs = aggretastic.Aggregations{"by_country": byCountry, "by_status": byStatus}
+
+		res = &elastic.SearchResult{}
+		Expect(json.Unmarshal([]byte(response), res)).To(Succeed())
+	})
+
+	It("should return the typed value for every parent bucket", func() {
+		results, err := aggs.SelectResult(res, "by_country", "revenue")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+
+		Expect(results[0].Buckets).To(HaveLen(1))
+		Expect(results[0].Buckets[0].Name).To(Equal("by_country"))
+		Expect(results[0].Buckets[0].Key).To(Equal("de"))
+		Expect(results[0].Buckets[0].DocCount).To(BeEquivalentTo(3))
+		Expect(*results[0].Value.(*elastic.AggregationValueMetric).Value).To(Equal(30.0))
+		Expect(*results[1].Value.(*elastic.AggregationValueMetric).Value).To(Equal(10.0))
+	})
+
+	It("should go through single bucket and keyed aggregations", func() {
+		results, err := aggs.SelectResult(res, "by_country", "paid", "revenue")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Buckets).To(HaveLen(2))
+		Expect(results[0].Buckets[1].Key).To(BeNil())
+		Expect(results[0].Buckets[1].DocCount).To(BeEquivalentTo(2))
+		Expect(*results[0].Value.(*elastic.AggregationValueMetric).Value).To(Equal(20.0))
+
+		results, err = aggs.SelectResult(res, "by_status", "price")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Buckets[0].Key).To(Equal("new"))
+		Expect(*results[0].Value.(*elastic.AggregationStatsMetric).Avg).To(Equal(2.5))
+	})
+
+	It("should return the bucket aggregation itself", func() {
+		results, err := aggs.SelectResult(res, "by_country")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Buckets).To(BeEmpty())
+		Expect(results[0].Value.(*elastic.AggregationBucketKeyItems).Buckets).To(HaveLen(2))
+	})
+
+	It("should report unknown paths", func() {
+		var pathErr *aggretastic.PathError
+
+		_, err := aggs.SelectResult(res, "by_country", "cost")
+		Expect(errors.Is(err, aggretastic.ErrSubAggNotFound)).To(BeTrue())
+		Expect(errors.As(err, &pathErr)).To(BeTrue())
+		Expect(pathErr.Path).To(Equal([]string{"by_country", "cost"}))
+
+		aggs.Inject(aggretastic.NewSumAggregation().Field("cost"), "by_country", "cost")
+		_, err = aggs.SelectResult(res, "by_country", "cost")
+		Expect(errors.Is(err, aggretastic.ErrResultNotFound)).To(BeTrue())
+		Expect(errors.As(err, &pathErr)).To(BeTrue())
+		Expect(pathErr.Path).To(Equal([]string{"by_country", "cost"}))
+		Expect(pathErr.Node).To(BeIdenticalTo(aggs.Select("by_country", "cost")))
+
+		_, err = aggs.SelectResult(res)
+		Expect(err.Error()).To(Equal("SelectResult (root): no path"))
+	})
+})
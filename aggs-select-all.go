@@ -0,0 +1,87 @@
+package aggretastic
+
+import "reflect"
+
+const (
+	// AnyName matches any single level of path in SelectAll() pattern
+	AnyName = "*"
+
+	// AnyDepth matches any number of levels (including zero) of path in SelectAll() pattern
+	AnyDepth = "**"
+)
+
+// Selection is an aggregation found by SelectAll() with its path
+type Selection struct {
+	Path []string
+	Agg  Aggregation
+}
+
+// SelectPredicate filters the aggregations matched by the pattern of SelectAll()
+type SelectPredicate func(path []string, agg Aggregation) bool
+
+// OfType returns the predicate which accepts the aggregations of the same type as the sample
+// e.g. OfType(&DateHistogramAggregation{})
+func OfType(sample Aggregation) SelectPredicate {
+	typ := reflect.TypeOf(sample)
+	return func(_ []string, agg Aggregation) bool {
+		return reflect.TypeOf(agg) == typ
+	}
+}
+
+// SelectAll returns every subAgg whose path matches the pattern and all the predicates.
+// The pattern is a path expression (see ParsePath()) where "*" matches any single level
+// and "**" matches any number of levels, e.g. "by_country>**" is by_country and all its descendants.
+// The aggregations are returned in pre-order, see Walk()
+func SelectAll(agg Aggregation, pattern string, predicates ...SelectPredicate) ([]Selection, error) {
+	return selectAll(func(fn WalkFunc) error { return Walk(agg, fn) }, pattern, predicates)
+}
+
+// SelectAll returns every aggregation of the map matching the pattern and all the predicates. See SelectAll()
+func (a *Aggregations) SelectAll(pattern string, predicates ...SelectPredicate) ([]Selection, error) {
+	return selectAll(a.Walk, pattern, predicates)
+}
+
+func selectAll(walk func(fn WalkFunc) error, pattern string, predicates []SelectPredicate) ([]Selection, error) {
+	patternPath, err := ParsePath(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	selections := make([]Selection, 0)
+	err = walk(func(path []string, agg Aggregation) error {
+		if len(path) == 0 || !matchPathPattern(patternPath, path) {
+			return nil
+		}
+		for _, predicate := range predicates {
+			if !predicate(path, agg) {
+				return nil
+			}
+		}
+
+		selections = append(selections, Selection{Path: path, Agg: agg})
+		return nil
+	})
+
+	return selections, err
+}
+
+// matchPathPattern checks if the whole path matches the pattern
+func matchPathPattern(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	switch pattern[0] {
+	case AnyDepth:
+		for skip := 0; skip <= len(path); skip++ {
+			if matchPathPattern(pattern[1:], path[skip:]) {
+				return true
+			}
+		}
+		return false
+	case AnyName:
+		return len(path) > 0 && matchPathPattern(pattern[1:], path[1:])
+	default:
+		return len(path) > 0 && pattern[0] == path[0] && matchPathPattern(pattern[1:], path[1:])
+	}
+}
@@ -0,0 +1,84 @@
+package aggretastic_test
+
+import (
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelectAll", func() {
+
+	var agg *aggretastic.FilterAggregation
+
+	BeforeEach(func() {
+		agg = aggretastic.NewFilterAggregation()
+		agg.Inject(aggretastic.NewTermsAggregation().Field("country"), "by_country")
+		agg.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_country", "by_day")
+		agg.Inject(aggretastic.NewTermsAggregation().Field("city"), "by_country", "by_day", "by_city")
+		agg.Inject(aggretastic.NewSumAggregation().Field("price"), "by_country", "by_day", "revenue")
+		agg.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_month")
+	})
+
+	paths := func(selections []aggretastic.Selection) [][]string {
+		result := make([][]string, len(selections))
+		for i, s := range selections {
+			result[i] = s.Path
+		}
+		return result
+	}
+
+	It("should match single levels with *", func() {
+		selections, err := aggretastic.SelectAll(agg, "by_country>*>*")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths(selections)).To(Equal([][]string{
+			{"by_country", "by_day", "by_city"},
+			{"by_country", "by_day", "revenue"},
+		}))
+		Expect(selections[1].Agg).To(Equal(agg.Select("by_country", "by_day", "revenue")))
+
+		selections, err = aggretastic.SelectAll(agg, "*")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths(selections)).To(Equal([][]string{{"by_country"}, {"by_month"}}))
+	})
+
+	It("should match any depth with **", func() {
+		selections, err := aggretastic.SelectAll(agg, "by_country>**")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths(selections)).To(Equal([][]string{
+			{"by_country"},
+			{"by_country", "by_day"},
+			{"by_country", "by_day", "by_city"},
+			{"by_country", "by_day", "revenue"},
+		}))
+
+		selections, err = aggretastic.SelectAll(agg, "**>revenue")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths(selections)).To(Equal([][]string{{"by_country", "by_day", "revenue"}}))
+	})
+
+	It("should filter by type", func() {
+		selections, err := aggretastic.SelectAll(agg, "**", aggretastic.OfType(&aggretastic.DateHistogramAggregation{}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths(selections)).To(Equal([][]string{{"by_country", "by_day"}, {"by_month"}}))
+
+		for _, s := range selections {
+			s.Agg.(*aggretastic.DateHistogramAggregation).TimeZone("Europe/Paris")
+		}
+		src, _ := agg.Select("by_month").Source()
+		Expect(src).To(HaveKeyWithValue("date_histogram", HaveKeyWithValue("time_zone", "Europe/Paris")))
+
+		selections, err = aggretastic.SelectAll(agg, "by_country>**", aggretastic.OfType(&aggretastic.TermsAggregation{}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths(selections)).To(Equal([][]string{{"by_country"}, {"by_country", "by_day", "by_city"}}))
+	})
+
+	It("should select from the map of aggregations", func() {
+		aggs := aggretastic.Aggregations{"filtered": agg}
+		selections, err := aggs.SelectAll("*>*>by_day")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths(selections)).To(Equal([][]string{{"filtered", "by_country", "by_day"}}))
+
+		_, err = aggs.SelectAll("a>>b")
+		Expect(err).To(MatchError(aggretastic.ErrInvalidPath))
+	})
+})
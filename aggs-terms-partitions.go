@@ -0,0 +1,150 @@
+package aggretastic
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/olivere/elastic/v7"
+)
+
+var ErrAggIsNotTerms = fmt.Errorf("agg is not terms")
+var ErrTermsHasInclude = fmt.Errorf("terms include can't be combined with partitions")
+
+// SweepTermsPartitions requests every partition 0..numPartitions-1 of terms aggregation located by path and merges their buckets.
+// A clone of aggregations is made for every partition with the `include.partition` and `include.num_partitions` rewritten,
+// so the aggregations themselves are not changed.
+// Up to `concurrency` searches are run at the same time (1 if concurrency < 1). The first error cancels the rest of searches.
+// The buckets are merged in order of partitions.
+// Elasticsearch doesn't allow the partitions together with include regexp or values, so such terms are rejected.
+// The terms must have a single result: the terms under a multi-bucket aggregation (e.g. terms in terms) are rejected
+// with ErrPathNotSelectable, their buckets would need to be merged per parent bucket.
+func (a *Aggregations) SweepTermsPartitions(ctx context.Context, search SearchFunc, numPartitions, concurrency int, path ...string) (*elastic.AggregationBucketKeyItems, error) {
+	if len(path) == 0 {
+		return nil, &PathError{Op: "SweepTermsPartitions", Err: ErrNoPath}
+	}
+	if numPartitions < 1 {
+		return nil, fmt.Errorf("invalid number of partitions: %d", numPartitions)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	agg := a.Select(path...)
+	if agg == nil {
+		return nil, &PathError{Op: "SweepTermsPartitions", Path: missingPath(a, path), Err: ErrSubAggNotFound}
+	}
+	terms, ok := agg.(*TermsAggregation)
+	if !ok {
+		return nil, &PathError{Op: "SweepTermsPartitions", Path: joinPath(path), Node: agg, Err: ErrAggIsNotTerms}
+	}
+	if ie := terms.includeExclude; ie != nil && (ie.Include != "" || len(ie.IncludeValues) > 0) {
+		return nil, &PathError{Op: "SweepTermsPartitions", Path: joinPath(path), Node: agg, Err: ErrTermsHasInclude}
+	}
+	for i := 1; i < len(path); i++ {
+		if parent := a.Select(path[:i]...); isMultiBucketAggregation(parent) {
+			return nil, &PathError{Op: "SweepTermsPartitions", Path: joinPath(path[:i]), Node: parent, Err: ErrPathNotSelectable}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	partitions := make([]*elastic.AggregationBucketKeyItems, numPartitions)
+	errs := make([]error, numPartitions)
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for partition := 0; partition < numPartitions; partition++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			errs[partition] = ctx.Err()
+			break
+		}
+
+		wg.Add(1)
+		go func(partition int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			partitions[partition], errs[partition] = a.searchTermsPartition(ctx, search, partition, numPartitions, path)
+			if errs[partition] != nil {
+				cancel()
+			}
+		}(partition)
+	}
+	wg.Wait()
+
+	if err := firstPartitionError(errs); err != nil {
+		return nil, err
+	}
+
+	return mergeTermsPartitions(partitions), nil
+}
+
+func (a *Aggregations) searchTermsPartition(ctx context.Context, search SearchFunc, partition, numPartitions int, path []string) (*elastic.AggregationBucketKeyItems, error) {
+	aggs := a.Clone()
+	aggs.Select(path...).(*TermsAggregation).Partition(partition).NumPartitions(numPartitions)
+
+	res, err := search(ctx, aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := aggs.SelectResult(res, path...)
+	if err != nil {
+		return nil, withPathPrefix("SweepTermsPartitions", nil, err)
+	}
+	if len(results) != 1 {
+		return nil, &PathError{Op: "SweepTermsPartitions", Path: joinPath(path), Node: aggs.Select(path...), Err: fmt.Errorf("%w: %d terms results", ErrPathNotSelectable, len(results))}
+	}
+
+	items, ok := results[0].Value.(*elastic.AggregationBucketKeyItems)
+	if !ok {
+		return nil, &PathError{Op: "SweepTermsPartitions", Path: joinPath(path), Node: results[0].Aggregation, Err: ErrResultNotFound}
+	}
+
+	return items, nil
+}
+
+// firstPartitionError returns the cause of failure: the errors of cancelled searches are ignored if there is another one
+func firstPartitionError(errs []error) error {
+	var cancelled error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if err != context.Canceled {
+			return err
+		}
+		cancelled = err
+	}
+	return cancelled
+}
+
+// mergeTermsPartitions joins the buckets of partitions.
+// The partitions don't share terms so the buckets are just concatenated
+func mergeTermsPartitions(partitions []*elastic.AggregationBucketKeyItems) *elastic.AggregationBucketKeyItems {
+	merged := &elastic.AggregationBucketKeyItems{
+		Buckets: make([]*elastic.AggregationBucketKeyItem, 0),
+	}
+
+	for _, items := range partitions {
+		if items == nil {
+			continue
+		}
+
+		merged.Buckets = append(merged.Buckets, items.Buckets...)
+		merged.SumOfOtherDocCount += items.SumOfOtherDocCount
+		if items.DocCountErrorUpperBound > merged.DocCountErrorUpperBound {
+			merged.DocCountErrorUpperBound = items.DocCountErrorUpperBound
+		}
+	}
+
+	return merged
+}
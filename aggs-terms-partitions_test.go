@@ -0,0 +1,125 @@
+package aggretastic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aahainc/aggretastic"
+	"github.com/olivere/elastic/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SweepTermsPartitions", func() {
+
+	var aggs aggretastic.Aggregations
+
+	// fakeSearch returns the customer of the requested partition
+	fakeSearch := func(ctx context.Context, request aggretastic.Aggregations) (*elastic.SearchResult, error) {
+		src, err := request.Select("customers").Source()
+		if err != nil {
+			return nil, err
+		}
+		include, ok := src.(map[string]interface{})["terms"].(map[string]interface{})["include"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("no partition in request")
+		}
+		partition := include["partition"].(int)
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"aggregations": map[string]interface{}{"customers": map[string]interface{}{
+				"sum_other_doc_count": 1,
+				"buckets": []map[string]interface{}{
+					{"key": fmt.Sprintf("customer-%d", partition), "doc_count": include["num_partitions"]},
+				},
+			}},
+		})
+		res := &elastic.SearchResult{}
+		return res, json.Unmarshal(body, res)
+	}
+
+	BeforeEach(func() {
+		aggs = aggretastic.Aggregations{"customers": aggretastic.NewTermsAggregation().Field("customer_id").Size(1000)}
+	})
+
+	It("should merge the buckets of all the partitions", func() {
+		for _, concurrency := range []int{0, 1, 3, 10} {
+			items, err := aggs.SweepTermsPartitions(context.Background(), fakeSearch, 5, concurrency, "customers")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			keys := make([]interface{}, 0)
+			for _, bucket := range items.Buckets {
+				keys = append(keys, bucket.Key)
+				Expect(bucket.DocCount).To(BeEquivalentTo(5))
+			}
+			Expect(keys).To(Equal([]interface{}{"customer-0", "customer-1", "customer-2", "customer-3", "customer-4"}))
+			Expect(items.SumOfOtherDocCount).To(BeEquivalentTo(5))
+		}
+
+		src, _ := aggs.Select("customers").Source()
+		Expect(src.(map[string]interface{})["terms"]).NotTo(HaveKey("include"))
+	})
+
+	It("should return the first error", func() {
+		failure := fmt.Errorf("boom")
+		mu := sync.Mutex{}
+		calls := 0
+		_, err := aggs.SweepTermsPartitions(context.Background(), func(ctx context.Context, request aggretastic.Aggregations) (*elastic.SearchResult, error) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			return nil, failure
+		}, 20, 2, "customers")
+		Expect(err).To(Equal(failure))
+		Expect(calls).To(BeNumerically("<", 20))
+	})
+
+	It("should check the path", func() {
+		aggs["total"] = aggretastic.NewSumAggregation().Field("price")
+		_, err := aggs.SweepTermsPartitions(context.Background(), fakeSearch, 5, 1, "total")
+		Expect(errors.Is(err, aggretastic.ErrAggIsNotTerms)).To(BeTrue())
+
+		var pathErr *aggretastic.PathError
+		Expect(errors.As(err, &pathErr)).To(BeTrue())
+		Expect(pathErr.Op).To(Equal("SweepTermsPartitions"))
+		Expect(pathErr.Path).To(Equal([]string{"total"}))
+		Expect(pathErr.Node).To(BeIdenticalTo(aggs["total"]))
+	})
+
+	It("should reject the terms under multi-bucket aggregations", func() {
+		byCountry := aggretastic.NewTermsAggregation().Field("country")
+		byCountry.Inject(aggs["customers"], "customers")
+		aggs = aggretastic.Aggregations{"by_country": byCountry}
+
+		calls := 0
+		_, err := aggs.SweepTermsPartitions(context.Background(), func(ctx context.Context, request aggretastic.Aggregations) (*elastic.SearchResult, error) {
+			calls++
+			return nil, fmt.Errorf("unexpected search")
+		}, 5, 1, "by_country", "customers")
+		Expect(errors.Is(err, aggretastic.ErrPathNotSelectable)).To(BeTrue())
+		Expect(calls).To(BeZero())
+
+		var pathErr *aggretastic.PathError
+		Expect(errors.As(err, &pathErr)).To(BeTrue())
+		Expect(pathErr.Path).To(Equal([]string{"by_country"}))
+	})
+
+	It("should reject the terms with include", func() {
+		for _, terms := range []*aggretastic.TermsAggregation{
+			aggretastic.NewTermsAggregation().Field("customer_id").Include("a.*"),
+			aggretastic.NewTermsAggregation().Field("customer_id").IncludeValues("a", "b"),
+		} {
+			aggs["customers"] = terms
+			calls := 0
+			_, err := aggs.SweepTermsPartitions(context.Background(), func(ctx context.Context, request aggretastic.Aggregations) (*elastic.SearchResult, error) {
+				calls++
+				return fakeSearch(ctx, request)
+			}, 5, 1, "customers")
+			Expect(errors.Is(err, aggretastic.ErrTermsHasInclude)).To(BeTrue())
+			Expect(calls).To(BeZero())
+		}
+	})
+})
@@ -0,0 +1,61 @@
+package aggretastic
+
+// TransformFunc is the type of the function called for each aggregation by Transform.
+// The returned aggregation replaces the visited one with its whole subtree:
+// return the visited aggregation itself (changed or not) to keep it, a new one to replace or wrap it, nil to remove it
+type TransformFunc func(path []string, agg Aggregation) (Aggregation, error)
+
+// Transform rebuilds the copy of aggregation tree calling fn for every aggregation in post-order:
+// the subAggregations are already transformed when fn is called for their parent,
+// so the aggregation returned by fn is not transformed again (wrapping doesn't loop).
+// Replaced aggregations keep their positions. The root has an empty path; nil is returned when fn removes it.
+// The original tree is not changed
+func Transform(agg Aggregation, fn TransformFunc) (Aggregation, error) {
+	return transform([]string{}, agg.Clone(), fn)
+}
+
+// Transform rebuilds the copy of the map of aggregations. See Transform()
+func (a *Aggregations) Transform(fn TransformFunc) (Aggregations, error) {
+	result := make(Aggregations)
+	if a == nil {
+		return result, nil
+	}
+
+	for _, name := range sortedNames(*a) {
+		agg, err := transform([]string{name}, (*a)[name].Clone(), fn)
+		if err != nil {
+			return nil, err
+		}
+		if agg != nil {
+			result[name] = agg
+		}
+	}
+
+	return result, nil
+}
+
+func transform(path []string, agg Aggregation, fn TransformFunc) (Aggregation, error) {
+	for _, name := range agg.GetSubNames() {
+		subAgg := agg.Select(name)
+		if subAgg == nil {
+			continue
+		}
+
+		transformed, err := transform(joinPath(path, name), subAgg, fn)
+		if err != nil {
+			return nil, err
+		}
+
+		if transformed == nil {
+			agg.Pop(name)
+			continue
+		}
+		if transformed != subAgg {
+			if _, err := agg.Inject(transformed, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return fn(joinPath(path), agg)
+}
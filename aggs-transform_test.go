@@ -0,0 +1,88 @@
+package aggretastic_test
+
+import (
+	"github.com/aahainc/aggretastic"
+	"github.com/olivere/elastic/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transform", func() {
+
+	var agg *aggretastic.TermsAggregation
+
+	BeforeEach(func() {
+		agg = aggretastic.NewTermsAggregation().Field("country")
+		agg.Inject(aggretastic.NewTermsAggregation().Field("city"), "by_city")
+		agg.Inject(aggretastic.NewSumAggregation().Field("price"), "by_city", "revenue")
+		agg.Inject(aggretastic.NewAvgAggregation().Field("price"), "avg_price")
+		agg.Inject(aggretastic.NewCardinalityAggregation().Field("customer"), "customers")
+	})
+
+	It("should replace, wrap and remove nodes keeping the original tree", func() {
+		original, _ := agg.Source()
+
+		result, err := aggretastic.Transform(agg, func(path []string, node aggretastic.Aggregation) (aggretastic.Aggregation, error) {
+			switch n := node.(type) {
+			case *aggretastic.TermsAggregation:
+				// the fields are not readable: restore them by the path
+				if len(path) == 0 {
+					return n.Field("country.keyword"), nil
+				}
+				return n.Field("city.keyword"), nil
+			case *aggretastic.SumAggregation:
+				wrapper := aggretastic.NewFilterAggregation().Filter(elastic.NewTermQuery("tenant", "acme"))
+				_, err := wrapper.Inject(n, "value")
+				return wrapper, err
+			case *aggretastic.CardinalityAggregation:
+				return nil, nil
+			}
+			return node, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.GetSubNames()).To(Equal([]string{"by_city", "avg_price"}))
+		Expect(result.ExtractLeafPaths()).To(Equal([][]string{{"by_city", "revenue", "value"}, {"avg_price"}}))
+
+		src, _ := result.Select("by_city").Source()
+		Expect(src).To(HaveKeyWithValue("terms", HaveKeyWithValue("field", "city.keyword")))
+		src, _ = result.Source()
+		Expect(src).To(HaveKeyWithValue("terms", HaveKeyWithValue("field", "country.keyword")))
+
+		src, _ = agg.Source()
+		Expect(src).To(Equal(original))
+	})
+
+	It("should transform the root and stop on error", func() {
+		result, err := aggretastic.Transform(agg, func(path []string, node aggretastic.Aggregation) (aggretastic.Aggregation, error) {
+			if len(path) == 0 {
+				return nil, nil
+			}
+			return node, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(BeNil())
+
+		_, err = aggretastic.Transform(agg, func(path []string, node aggretastic.Aggregation) (aggretastic.Aggregation, error) {
+			return nil, aggretastic.ErrSubAggNotFound
+		})
+		Expect(err).To(MatchError(aggretastic.ErrSubAggNotFound))
+	})
+
+	It("should transform the map of aggregations", func() {
+		aggs := aggretastic.Aggregations{
+			"by_country": agg,
+			"total":      aggretastic.NewValueCountAggregation().Field("id"),
+		}
+
+		result, err := aggs.Transform(func(path []string, node aggretastic.Aggregation) (aggretastic.Aggregation, error) {
+			if len(path) == 1 && path[0] == "total" {
+				return nil, nil
+			}
+			return node, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(HaveLen(1))
+		Expect(result).To(HaveKey("by_country"))
+		Expect(aggs).To(HaveLen(2))
+	})
+})
@@ -1,7 +1,6 @@
 package aggretastic
 
 import (
-	"fmt"
 	"github.com/olivere/elastic/v7"
 )
 
@@ -18,43 +17,80 @@ func IsNotInjectable(agg Aggregation) bool {
 	return ok
 }
 
+// ExtractLeafPaths returns the only empty path: notInjectable agg can't have subAggregations so it's a leaf itself
 func (a *notInjectable) ExtractLeafPaths() (leafs [][]string) {
-	return make([][]string, 0)
+	return [][]string{{}}
 }
 
 func (a *notInjectable) Inject(subAggregation Aggregation, path ...string) (resultPaths [][]string, err error) {
-	err = ErrAggIsNotInjectable
+	err = a.notInjectableError("Inject")
 	return
 }
 
 func (a *notInjectable) InjectX(subAggregation Aggregation, path ...string) (resultPaths [][]string, err error) {
-	err = ErrAggIsNotInjectable
+	err = a.notInjectableError("InjectX")
 	return
 }
 
 func (a *notInjectable) InjectSafe(subAggregation Aggregation, path ...string) (resultPaths [][]string, err error) {
-	err = ErrAggIsNotInjectable
+	err = a.notInjectableError("InjectSafe")
 	return
 }
 
+// notInjectableError reports the aggregation itself as the node which rejected the operation
+func (a *notInjectable) notInjectableError(op string) error {
+	node, _ := a.root.(Aggregation)
+	return &PathError{Op: op, Path: []string{}, Node: node, Err: ErrAggIsNotInjectable}
+}
+
 func (a *notInjectable) GetAllSubs() map[string]Aggregation {
 	return nil
 }
 
+func (a *notInjectable) GetSubNames() []string {
+	return nil
+}
+
+func (a *notInjectable) MoveBefore(name, mark string) error {
+	return ErrSubAggNotFound
+}
+
+func (a *notInjectable) MoveAfter(name, mark string) error {
+	return ErrSubAggNotFound
+}
+
 func (a *notInjectable) Select(path ...string) Aggregation {
 	// nothing to select because of no subAggregations
-	s, _ := a.root.Source()
-	fmt.Printf("notInjectable.Select() is ignored. The aggregation doesn't allow to have subAggregations. Root: %s", s)
+	a.logIgnored("Select")
 	return nil
 }
 
 func (a *notInjectable) Pop(path ...string) Aggregation {
-	// nothing to select because of no subAggregations
-	s, _ := a.root.Source()
-	fmt.Printf("notInjectable.Select() is ignored. The aggregation doesn't allow to have subAggregations. Root: %s", s)
+	// nothing to pop because of no subAggregations
+	a.logIgnored("Pop")
 	return nil
 }
 
+// logIgnored reports the ignored operation through the logger of the package. See SetLogger()
+func (a *notInjectable) logIgnored(op string) {
+	if currentLogger() == nil {
+		// don't build the source for nothing
+		return
+	}
+
+	s, _ := a.root.Source()
+	logf("notInjectable.%s() is ignored. The aggregation doesn't allow to have subAggregations. Root: %v", op, s)
+}
+
+// Clone returns a deep copy of the root aggregation when it's possible.
+// Foreign elastic aggregations can't be copied so they are shared
+func (a *notInjectable) Clone() Aggregation {
+	if root, ok := a.root.(Aggregation); ok {
+		return root.Clone()
+	}
+	return newNotInjectable(a.root)
+}
+
 func (a *notInjectable) Export() elastic.Aggregation {
 	return a.root
 }
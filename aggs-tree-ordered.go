@@ -0,0 +1,185 @@
+package aggretastic
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// orderedAggregations is a container of named subAggregations which keeps the order of insertion.
+// The order is used everywhere the children are iterated: Source(), GetSubNames(), ExtractLeafPaths() etc.
+type orderedAggregations struct {
+	names []string
+	aggs  map[string]Aggregation
+}
+
+func newOrderedAggregations() *orderedAggregations {
+	return &orderedAggregations{
+		names: make([]string, 0),
+		aggs:  make(map[string]Aggregation),
+	}
+}
+
+// Len returns the number of subAggregations
+func (o *orderedAggregations) Len() int {
+	return len(o.names)
+}
+
+// Get returns a subAggregation by its name
+func (o *orderedAggregations) Get(name string) (agg Aggregation, ok bool) {
+	agg, ok = o.aggs[name]
+	return
+}
+
+// Set puts a subAggregation to the end of container.
+// The existing subAggregation is replaced keeping its position
+func (o *orderedAggregations) Set(name string, agg Aggregation) {
+	if _, ok := o.aggs[name]; !ok {
+		o.names = append(o.names, name)
+	}
+	o.aggs[name] = agg
+}
+
+// Delete removes a subAggregation by its name
+func (o *orderedAggregations) Delete(name string) {
+	if _, ok := o.aggs[name]; !ok {
+		return
+	}
+
+	delete(o.aggs, name)
+	o.names = removeName(o.names, o.indexOf(name))
+}
+
+// Rename changes the name of a subAggregation keeping its position
+func (o *orderedAggregations) Rename(name, newName string) error {
+	agg, ok := o.aggs[name]
+	if !ok {
+		return ErrSubAggNotFound
+	}
+	if name == newName {
+		return nil
+	}
+	if _, ok := o.aggs[newName]; ok {
+		return ErrSubAggExists
+	}
+
+	delete(o.aggs, name)
+	o.aggs[newName] = agg
+	o.names[o.indexOf(name)] = newName
+	return nil
+}
+
+// Names returns the names of subAggregations in their order
+func (o *orderedAggregations) Names() []string {
+	names := make([]string, len(o.names))
+	copy(names, o.names)
+	return names
+}
+
+// Map returns a copy of the subAggregations map
+func (o *orderedAggregations) Map() map[string]Aggregation {
+	aggs := make(map[string]Aggregation, len(o.aggs))
+	for name, agg := range o.aggs {
+		aggs[name] = agg
+	}
+	return aggs
+}
+
+// MoveBefore moves the subAggregation `name` right before the subAggregation `mark`
+func (o *orderedAggregations) MoveBefore(name, mark string) error {
+	return o.move(name, mark, 0)
+}
+
+// MoveAfter moves the subAggregation `name` right after the subAggregation `mark`
+func (o *orderedAggregations) MoveAfter(name, mark string) error {
+	return o.move(name, mark, 1)
+}
+
+func (o *orderedAggregations) move(name, mark string, shift int) error {
+	if _, ok := o.aggs[name]; !ok {
+		return ErrSubAggNotFound
+	}
+	if _, ok := o.aggs[mark]; !ok {
+		return ErrSubAggNotFound
+	}
+	if name == mark {
+		return nil
+	}
+
+	names := removeName(o.names, o.indexOf(name))
+	at := indexOfName(names, mark) + shift
+
+	o.names = append(names[:at], append([]string{name}, names[at:]...)...)
+	return nil
+}
+
+func (o *orderedAggregations) indexOf(name string) int {
+	return indexOfName(o.names, name)
+}
+
+// Source returns the JSON-serializable source of subAggregations keeping their order
+func (o *orderedAggregations) Source() (interface{}, error) {
+	source := &orderedSource{
+		keys:   o.Names(),
+		values: make(map[string]interface{}, len(o.names)),
+	}
+
+	for _, name := range o.names {
+		src, err := o.aggs[name].Source()
+		if err != nil {
+			return nil, err
+		}
+		source.values[name] = src
+	}
+
+	return source, nil
+}
+
+// orderedSource is a JSON-serializable object which keeps the order of its keys
+type orderedSource struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// MarshalJSON writes the object keys in their order
+func (s *orderedSource) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+
+	for i, key := range s.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+
+		v, err := json.Marshal(s.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func indexOfName(names []string, name string) int {
+	for i := range names {
+		if names[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeName returns a new slice without the i-th name
+func removeName(names []string, i int) []string {
+	result := make([]string, 0, len(names))
+	result = append(result, names[:i]...)
+	return append(result, names[i+1:]...)
+}
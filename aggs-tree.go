@@ -3,13 +3,15 @@ package aggretastic
 import (
 	"fmt"
 	"github.com/olivere/elastic/v7"
-	"log"
+	"sort"
 )
 
 var (
 	ErrNoPath             = fmt.Errorf("no path")
 	ErrPathNotSelectable  = fmt.Errorf("path is not selectable")
 	ErrAggIsNotInjectable = fmt.Errorf("agg is not injectable")
+	ErrSubAggNotFound     = fmt.Errorf("subAgg is not found")
+	ErrSubAggExists       = fmt.Errorf("subAgg already exists")
 )
 
 // Aggregation is a tree-ish version of original elastic.Aggregation
@@ -20,10 +22,22 @@ type Aggregation interface {
 	// is used to support call of `.Source()` method from aggregations' code
 	elastic.Aggregation
 
-	// GetAllSubs returns the map of this aggregation's subAggregations
+	// GetAllSubs returns the map of this aggregation's subAggregations.
+	// The map is a copy: changing it doesn't change the tree, use Inject() and Pop() for that.
+	// The map has no order, see GetSubNames()
 	GetAllSubs() map[string]Aggregation
 
-	// Inject sets new subAgg into the map of subAggregations
+	// GetSubNames returns the names of this aggregation's subAggregations in their order
+	GetSubNames() []string
+
+	// MoveBefore moves the subAgg `name` right before the subAgg `mark`
+	MoveBefore(name, mark string) error
+
+	// MoveAfter moves the subAgg `name` right after the subAgg `mark`
+	MoveAfter(name, mark string) error
+
+	// Inject sets new subAgg into the map of subAggregations.
+	// The errors of Inject, InjectX and InjectSafe are *PathError
 	Inject(subAgg Aggregation, path ...string) (resultPaths [][]string, err error)
 
 	// InjectX sets new subAgg into the map of subAggregations only if it NOT exists already
@@ -38,11 +52,20 @@ type Aggregation interface {
 	// Pop returns a subAgg by it's path and remove it from tree
 	Pop(path ...string) Aggregation
 
+	// SelectPath, PopPath and InjectPath are the variants of Select, Pop and Inject
+	// taking the path expression like "by_country>by_day>revenue"
+	SelectPath(expr string) Aggregation
+	PopPath(expr string) Aggregation
+	InjectPath(subAgg Aggregation, expr string) (resultPaths [][]string, err error)
+
 	// Export returns the same object in original Agg interface
 	Export() elastic.Aggregation
 
 	// ExtractLeafPaths returns paths the leafs
 	ExtractLeafPaths() [][]string
+
+	// Clone returns a deep copy of the aggregation with all its subAggregations
+	Clone() Aggregation
 }
 
 func IsNilTree(t Aggregation) bool {
@@ -51,29 +74,31 @@ func IsNilTree(t Aggregation) bool {
 
 type tree struct {
 	root            elastic.Aggregation
-	subAggregations map[string]Aggregation
+	subAggregations *orderedAggregations
 }
 
 func nilAggregationTree(root elastic.Aggregation) *tree {
 	return &tree{
 		root:            root,
-		subAggregations: make(map[string]Aggregation),
+		subAggregations: newOrderedAggregations(),
 	}
 }
 
 func (a *tree) ExtractLeafPaths() (leafs [][]string) {
 	leafs = make([][]string, 0)
 
-	if len(a.subAggregations) == 0 {
+	if a.subAggregations.Len() == 0 {
 		leafs = append(leafs, []string{})
 		return
 	}
 
-	for leafName, leafAgg := range a.subAggregations {
+	for _, leafName := range a.subAggregations.Names() {
+		leafAgg, _ := a.subAggregations.Get(leafName)
 		extractedLeafs := leafAgg.ExtractLeafPaths()
 		if len(extractedLeafs) == 0 {
+			// the sub aggregation is a leaf itself
 			leafs = append(leafs, []string{leafName})
-			break
+			continue
 		}
 		for _, leaf := range extractedLeafs {
 			leafs = append(leafs, append([]string{leafName}, leaf...))
@@ -87,14 +112,20 @@ func (a *tree) Inject(subAggregation Aggregation, path ...string) (resultPaths [
 	resultPaths = make([][]string, 0)
 
 	if len(path) == 0 {
-		err = ErrNoPath
+		err = &PathError{Op: "Inject", Err: ErrNoPath}
 		return
 	}
 
 	if len(path) == 1 {
-		a.subAggregations[path[0]] = subAggregation
+		if root, ok := a.root.(Aggregation); ok && isMetricAggregation(root) {
+			// Elasticsearch doesn't allow subAggregations under metrics
+			err = &PathError{Op: "Inject", Path: []string{}, Node: root, Err: ErrAggIsNotInjectable}
+			return
+		}
+
+		a.subAggregations.Set(path[0], subAggregation)
 		for _, leaf := range subAggregation.ExtractLeafPaths() {
-			resultPaths = append(resultPaths, append(path, leaf...))
+			resultPaths = append(resultPaths, joinPath(path, leaf...))
 		}
 		return
 	}
@@ -102,15 +133,16 @@ func (a *tree) Inject(subAggregation Aggregation, path ...string) (resultPaths [
 	// deeper inject
 	cursor := a.Select(path[:len(path)-1]...)
 	if IsNilTree(cursor) {
-		err = ErrPathNotSelectable
+		err = &PathError{Op: "Inject", Path: missingPath(a, path[:len(path)-1]), Err: ErrPathNotSelectable}
 		return
 	}
 
 	if resultPaths, err = cursor.Inject(subAggregation, path[len(path)-1]); err != nil {
+		err = withPathPrefix("Inject", path[:len(path)-1], err)
 		return
 	} else {
 		for j := range resultPaths {
-			resultPaths[j] = append(path[:len(path)-1], resultPaths[j]...)
+			resultPaths[j] = joinPath(path[:len(path)-1], resultPaths[j]...)
 		}
 	}
 
@@ -121,13 +153,14 @@ func (a *tree) InjectX(subAggregation Aggregation, path ...string) (resultPaths
 	resultPaths = make([][]string, 0)
 
 	if len(path) == 0 {
-		err = ErrNoPath
+		err = &PathError{Op: "InjectX", Err: ErrNoPath}
 		return
 	}
 
 	if alreadyInjected := a.Select(path...); IsNilTree(alreadyInjected) {
 		resultPaths, err = a.Inject(subAggregation, path...)
 		if err != nil {
+			err = withPathPrefix("InjectX", nil, err)
 			return
 		}
 	}
@@ -139,7 +172,7 @@ func (a *tree) InjectSafe(subAggregation Aggregation, path ...string) (resultPat
 	resultPaths = make([][]string, 0)
 
 	if len(path) == 0 {
-		err = ErrNoPath
+		err = &PathError{Op: "InjectSafe", Err: ErrNoPath}
 		return
 	}
 
@@ -147,21 +180,25 @@ func (a *tree) InjectSafe(subAggregation Aggregation, path ...string) (resultPat
 	subTree := a.Select(path...)
 
 	if IsNilTree(subTree) {
-		return a.Inject(subAggregation, path...)
+		if resultPaths, err = a.Inject(subAggregation, path...); err != nil {
+			err = withPathPrefix("InjectSafe", nil, err)
+		}
+		return
 	}
 
-	for k, subAggDeep := range subAggregation.GetAllSubs() {
-		kResultPaths, injectErr := subTree.InjectSafe(subAggDeep, k)
+	subAggsDeep := subAggregation.GetAllSubs()
+	for _, k := range subAggregation.GetSubNames() {
+		kResultPaths, injectErr := subTree.InjectSafe(subAggsDeep[k], k)
 		if injectErr != nil {
-			err = injectErr
+			err = withPathPrefix("InjectSafe", path, injectErr)
 			return
 		}
 		if len(kResultPaths) > 0 {
 			for j := range kResultPaths {
-				kResultPaths[j] = append(path, kResultPaths[j]...)
+				kResultPaths[j] = joinPath(path, kResultPaths[j]...)
 			}
 		} else {
-			kResultPaths = append(kResultPaths, append(path, k))
+			kResultPaths = append(kResultPaths, joinPath(path, k))
 		}
 
 		resultPaths = append(resultPaths, kResultPaths...)
@@ -171,7 +208,30 @@ func (a *tree) InjectSafe(subAggregation Aggregation, path ...string) (resultPat
 }
 
 func (a *tree) GetAllSubs() map[string]Aggregation {
-	return a.subAggregations
+	return a.subAggregations.Map()
+}
+
+func (a *tree) GetSubNames() []string {
+	return a.subAggregations.Names()
+}
+
+func (a *tree) MoveBefore(name, mark string) error {
+	return a.subAggregations.MoveBefore(name, mark)
+}
+
+func (a *tree) MoveAfter(name, mark string) error {
+	return a.subAggregations.MoveAfter(name, mark)
+}
+
+// renameSubAggregation changes the name of direct subAgg keeping its position
+func (a *tree) renameSubAggregation(name, newName string) error {
+	return a.subAggregations.Rename(name, newName)
+}
+
+// subAggregation returns the direct subAggregation by name without copying the map of subAggregations
+func (a *tree) subAggregation(name string) Aggregation {
+	subAgg, _ := a.subAggregations.Get(name)
+	return subAgg
 }
 
 func (a *tree) Select(path ...string) Aggregation {
@@ -179,7 +239,7 @@ func (a *tree) Select(path ...string) Aggregation {
 		return nil
 	}
 
-	subAgg, ok := a.subAggregations[path[0]]
+	subAgg, ok := a.subAggregations.Get(path[0])
 	if !ok {
 		return nil
 	}
@@ -196,13 +256,13 @@ func (a *tree) Pop(path ...string) Aggregation {
 		return nil
 	}
 
-	subAgg, ok := a.subAggregations[path[0]]
+	subAgg, ok := a.subAggregations.Get(path[0])
 	if !ok {
 		return nil
 	}
 
 	if len(path) == 1 {
-		delete(a.subAggregations, path[0])
+		a.subAggregations.Delete(path[0])
 		return subAgg
 	}
 
@@ -231,6 +291,22 @@ func (a *Aggregations) Export() map[string]elastic.Aggregation {
 	return result
 }
 
+// ExtractLeafPaths returns paths of the leafs of every aggregation in the map
+func (a *Aggregations) ExtractLeafPaths() [][]string {
+	leafs := make([][]string, 0)
+	if a == nil {
+		return leafs
+	}
+
+	for _, name := range sortedNames(*a) {
+		for _, leaf := range (*a)[name].ExtractLeafPaths() {
+			leafs = append(leafs, append([]string{name}, leaf...))
+		}
+	}
+
+	return leafs
+}
+
 // Select selects an aggregation from the map (going deep forwarding the agg.Select() method)
 func (a *Aggregations) Select(path ...string) Aggregation {
 	if len(path) == 0 {
@@ -268,17 +344,35 @@ func (a *Aggregations) Pop(path ...string) Aggregation {
 	return base.Pop(path[1:]...)
 }
 
+// renameAggregation changes the name of aggregation in the map
+func (a *Aggregations) renameAggregation(name, newName string) error {
+	agg, ok := (*a)[name]
+	if !ok {
+		return ErrSubAggNotFound
+	}
+	if name == newName {
+		return nil
+	}
+	if _, ok := (*a)[newName]; ok {
+		return ErrSubAggExists
+	}
+
+	delete(*a, name)
+	(*a)[newName] = agg
+	return nil
+}
+
 // Inject just puts agg into the map of aggregations
 func (a *Aggregations) Inject(subAgg Aggregation, path ...string) (resultPaths [][]string, err error) {
 	resultPaths = make([][]string, 0)
 
 	if a == nil {
-		err = ErrAggIsNotInjectable
+		err = &PathError{Op: "Inject", Err: ErrAggIsNotInjectable}
 		return
 	}
 
 	if len(path) == 0 {
-		err = ErrNoPath
+		err = &PathError{Op: "Inject", Err: ErrNoPath}
 		return
 	}
 
@@ -287,7 +381,7 @@ func (a *Aggregations) Inject(subAgg Aggregation, path ...string) (resultPaths [
 	if len(path) == 1 {
 		(*a)[name] = subAgg
 		for _, leaf := range subAgg.ExtractLeafPaths() {
-			resultPaths = append(resultPaths, append(path, leaf...))
+			resultPaths = append(resultPaths, joinPath(path, leaf...))
 		}
 
 		return
@@ -295,15 +389,17 @@ func (a *Aggregations) Inject(subAgg Aggregation, path ...string) (resultPaths [
 
 	path = path[1:]
 	if _, ok := (*a)[name]; !ok {
-		err = ErrAggIsNotInjectable
+		err = &PathError{Op: "Inject", Path: []string{name}, Err: ErrAggIsNotInjectable}
 		return
 	}
 
 	resultPaths, err = (*a)[name].Inject(subAgg, path...)
-	if err == nil {
-		for i := range resultPaths {
-			resultPaths[i] = append([]string{name}, resultPaths[i]...)
-		}
+	if err != nil {
+		err = withPathPrefix("Inject", []string{name}, err)
+		return
+	}
+	for i := range resultPaths {
+		resultPaths[i] = append([]string{name}, resultPaths[i]...)
 	}
 	return
 }
@@ -312,12 +408,12 @@ func (a *Aggregations) InjectX(subAgg Aggregation, path ...string) (resultPaths
 	resultPaths = make([][]string, 0)
 
 	if a == nil {
-		err = ErrAggIsNotInjectable
+		err = &PathError{Op: "InjectX", Err: ErrAggIsNotInjectable}
 		return
 	}
 
 	if len(path) == 0 {
-		err = ErrNoPath
+		err = &PathError{Op: "InjectX", Err: ErrNoPath}
 		return
 	}
 
@@ -334,18 +430,21 @@ func (a *Aggregations) InjectX(subAgg Aggregation, path ...string) (resultPaths
 
 	path = path[1:]
 	if _, ok := (*a)[name]; !ok {
-		err = ErrAggIsNotInjectable
+		err = &PathError{Op: "InjectX", Path: []string{name}, Err: ErrAggIsNotInjectable}
 		return
 	}
 
-	return (*a)[name].InjectX(subAgg, path...)
+	if resultPaths, err = (*a)[name].InjectX(subAgg, path...); err != nil {
+		err = withPathPrefix("InjectX", []string{name}, err)
+	}
+	return
 }
 
 func (a *Aggregations) InjectSafe(subAgg Aggregation, path ...string) (resultPaths [][]string, err error) {
 	resultPaths = make([][]string, 0)
 
 	if len(path) == 0 {
-		err = ErrNoPath
+		err = &PathError{Op: "InjectSafe", Err: ErrNoPath}
 		return
 	}
 
@@ -363,21 +462,23 @@ func (a *Aggregations) InjectSafe(subAgg Aggregation, path ...string) (resultPat
 		}
 
 		// @todo
-		log.Println("warning! maybe unexpected behaviour. Edge case, need handling")
+		logf("warning! maybe unexpected behaviour. Edge case, need handling: %s already exists", name)
 		return
 	}
 
 	path = path[1:]
 	if _, ok := (*a)[name]; !ok {
-		err = ErrAggIsNotInjectable
+		err = &PathError{Op: "InjectSafe", Path: []string{name}, Err: ErrAggIsNotInjectable}
 		return
 	}
 
-	if resultPaths, err = (*a)[name].InjectSafe(subAgg, path...); err == nil {
-		// prepend name to result paths
-		for i := range resultPaths {
-			resultPaths[i] = append([]string{name}, resultPaths[i]...)
-		}
+	if resultPaths, err = (*a)[name].InjectSafe(subAgg, path...); err != nil {
+		err = withPathPrefix("InjectSafe", []string{name}, err)
+		return
+	}
+	// prepend name to result paths
+	for i := range resultPaths {
+		resultPaths[i] = append([]string{name}, resultPaths[i]...)
 	}
 
 	return
@@ -395,8 +496,8 @@ func getIntersectedPaths(rootAggs map[string]Aggregation, injectingAgg Aggregati
 	if len(rootAggs) == 0 {
 		rootLeafs = append(rootLeafs, []string{})
 	} else {
-		for leafName, leafAgg := range rootAggs {
-			for _, leaf := range leafAgg.ExtractLeafPaths() {
+		for _, leafName := range sortedNames(rootAggs) {
+			for _, leaf := range rootAggs[leafName].ExtractLeafPaths() {
 				rootLeafs = append(rootLeafs, append([]string{leafName}, leaf...))
 			}
 		}
@@ -404,7 +505,7 @@ func getIntersectedPaths(rootAggs map[string]Aggregation, injectingAgg Aggregati
 
 	givenLeafPaths := injectingAgg.ExtractLeafPaths()
 	for i := range givenLeafPaths {
-		givenLeafPaths[i] = append(injectPath, givenLeafPaths[i]...)
+		givenLeafPaths[i] = joinPath(injectPath, givenLeafPaths[i]...)
 	}
 
 	for _, resultLeaf := range rootLeafs {
@@ -418,6 +519,34 @@ func getIntersectedPaths(rootAggs map[string]Aggregation, injectingAgg Aggregati
 	return paths
 }
 
+// joinPath returns a new path of prefix and suffix which doesn't share the memory with any of them
+func joinPath(prefix []string, suffix ...string) []string {
+	path := make([]string, 0, len(prefix)+len(suffix))
+	path = append(path, prefix...)
+	return append(path, suffix...)
+}
+
+// sortedNames returns the names of aggregations in the sorted order
+func sortedNames(aggs map[string]Aggregation) []string {
+	names := make([]string, 0, len(aggs))
+	for name := range aggs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func sortedStrings(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
 // pathIsLeafOf checks if childPath is a finite leaf of parentPath
 func pathIsLeafOf(childPath, parentPath []string) bool {
 	if len(parentPath) < len(childPath) {
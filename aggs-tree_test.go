@@ -49,5 +49,134 @@ var _ = Describe("AggsTree", func() {
 			j, _ := json.Marshal(s)
 			Expect(string(j)).To(Equal(`{"aggregations":{"down":{"children":{"type":"child"}},"filtered":{"aggregations":{"deeper":{"aggregations":{"pre-final":{"aggregations":{"final":{"sum":{"field":"abc"}}},"filter":{"term":{"cc":"ee"}}}},"children":{"type":"foobar"}},"deeper-x":{"children":{"type":"foobarx"}}},"filter":{"term":{"b":"c"}}}},"filter":{"term":{"a":"b"}}}`))
 		})
+
+		It("should return every leaf in a stable order", func() {
+			agg := aggretastic.NewTermsAggregation().Field("country")
+			agg.Inject(aggretastic.NewSumAggregation().Field("price"), "revenue")
+			agg.Inject(aggretastic.NewDerivativeAggregation().BucketsPath("revenue"), "growth")
+			agg.Inject(aggretastic.NewDateHistogramAggregation().Field("date"), "by_day")
+			agg.Inject(aggretastic.NewAvgAggregation().Field("price"), "by_day", "avg")
+			agg.Inject(aggretastic.NewMaxBucketAggregation().BucketsPath("by_day>avg"), "best_day")
+
+			for i := 0; i < 20; i++ {
+				Expect(agg.ExtractLeafPaths()).To(Equal([][]string{
+					{"revenue"},
+					{"growth"},
+					{"by_day", "avg"},
+					{"best_day"},
+				}))
+			}
+		})
+
+		It("should return the path of injected pipeline aggregation", func() {
+			agg := aggretastic.NewTermsAggregation().Field("country")
+			resultPaths, err := agg.Inject(aggretastic.NewCumulativeSumAggregation().BucketsPath("_count"), "total")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(resultPaths).To(Equal([][]string{{"total"}}))
+		})
+	})
+
+	Context("Order of subAggregations", func() {
+
+		It("should keep the insertion order in the source", func() {
+			agg := aggretastic.NewTermsAggregation().Field("country").
+				SubAggregation("zeta", aggretastic.NewSumAggregation().Field("z")).
+				SubAggregation("alpha", aggretastic.NewSumAggregation().Field("a"))
+			agg.Inject(aggretastic.NewSumAggregation().Field("m"), "mu")
+
+			Expect(agg.GetSubNames()).To(Equal([]string{"zeta", "alpha", "mu"}))
+
+			for i := 0; i < 20; i++ {
+				src, err := agg.Source()
+				Expect(err).ShouldNot(HaveOccurred())
+				j, err := json.Marshal(src)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(j)).To(Equal(`{"aggregations":{"zeta":{"sum":{"field":"z"}},"alpha":{"sum":{"field":"a"}},"mu":{"sum":{"field":"m"}}},"terms":{"field":"country"}}`))
+			}
+		})
+
+		It("should keep the position of replaced and the order of the rest after pop", func() {
+			agg := aggretastic.NewFilterAggregation().Filter(elastic.NewMatchAllQuery())
+			agg.Inject(aggretastic.NewSumAggregation().Field("a"), "a")
+			agg.Inject(aggretastic.NewSumAggregation().Field("b"), "b")
+			agg.Inject(aggretastic.NewSumAggregation().Field("c"), "c")
+
+			agg.Inject(aggretastic.NewMaxAggregation().Field("a"), "a")
+			Expect(agg.GetSubNames()).To(Equal([]string{"a", "b", "c"}))
+
+			agg.Pop("b")
+			Expect(agg.GetSubNames()).To(Equal([]string{"a", "c"}))
+		})
+
+		It("should move subAggregations", func() {
+			agg := aggretastic.NewGlobalAggregation()
+			for _, name := range []string{"a", "b", "c", "d"} {
+				agg.Inject(aggretastic.NewSumAggregation().Field(name), name)
+			}
+
+			Expect(agg.MoveBefore("d", "a")).To(Succeed())
+			Expect(agg.GetSubNames()).To(Equal([]string{"d", "a", "b", "c"}))
+
+			Expect(agg.MoveAfter("a", "c")).To(Succeed())
+			Expect(agg.GetSubNames()).To(Equal([]string{"d", "b", "c", "a"}))
+
+			Expect(agg.MoveAfter("x", "c")).To(MatchError(aggretastic.ErrSubAggNotFound))
+			Expect(agg.GetSubNames()).To(Equal([]string{"d", "b", "c", "a"}))
+		})
+
+		It("should return a copy of subAggregations map", func() {
+			agg := aggretastic.NewGlobalAggregation()
+			agg.Inject(aggretastic.NewSumAggregation().Field("a"), "a")
+
+			subs := agg.GetAllSubs()
+			subs["b"] = aggretastic.NewSumAggregation().Field("b")
+			delete(subs, "a")
+
+			Expect(agg.GetSubNames()).To(Equal([]string{"a"}))
+			Expect(agg.Select("b")).To(BeNil())
+		})
+	})
+
+	Context("Clone", func() {
+
+		sourceOf := func(agg aggretastic.Aggregation) string {
+			src, err := agg.Source()
+			Expect(err).ShouldNot(HaveOccurred())
+			b, err := json.Marshal(src)
+			Expect(err).ShouldNot(HaveOccurred())
+			return string(b)
+		}
+
+		It("should not share anything mutable with the template", func() {
+			template := aggretastic.NewTermsAggregation().Field("country").Include("a.*").OrderByCountDesc()
+			template.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+			template.Inject(aggretastic.NewSumAggregation().Field("price").
+				Script(elastic.NewScript("_value * params.rate").Param("rate", 1.5)), "by_day", "revenue")
+			template.Inject(aggretastic.NewBucketScriptAggregation().AddBucketsPath("r", "revenue"), "by_day", "calc")
+			before := sourceOf(template)
+
+			clone := template.Clone().(*aggretastic.TermsAggregation)
+			Expect(sourceOf(clone)).To(MatchJSON(before))
+
+			clone.Include("b.*").OrderByTermAsc()
+			clone.Inject(aggretastic.NewAvgAggregation().Field("price"), "by_day", "avg_price")
+			clone.Select("by_day", "calc").(*aggretastic.BucketScriptAggregation).AddBucketsPath("a", "avg_price")
+
+			Expect(sourceOf(template)).To(MatchJSON(before))
+			Expect(sourceOf(clone)).NotTo(MatchJSON(before))
+		})
+
+		It("should not keep the caller's path in the result paths", func() {
+			sub := aggretastic.NewFilterAggregation().Filter(elastic.NewMatchAllQuery())
+			sub.Inject(aggretastic.NewSumAggregation().Field("x"), "x")
+			sub.Inject(aggretastic.NewSumAggregation().Field("y"), "y")
+
+			agg := aggretastic.NewGlobalAggregation()
+			path := make([]string, 1, 10)
+			path[0] = "sub"
+			resultPaths, err := agg.Inject(sub.Clone(), path...)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(resultPaths).To(Equal([][]string{{"sub", "x"}, {"sub", "y"}}))
+		})
 	})
 })
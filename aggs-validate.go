@@ -0,0 +1,36 @@
+package aggretastic
+
+// Validate checks the structure of aggregation tree before it's sent to Elasticsearch:
+//   - only bucket aggregations are allowed to have subAggregations: the error wraps ErrAggIsNotInjectable;
+//   - buckets_path of every pipeline aggregation is resolved in the tree: the error wraps ErrInvalidBucketsPath.
+//
+// The error is *PathError with the path of the first aggregation which breaks the rules
+func Validate(agg Aggregation) error {
+	return Walk(agg, validator(agg))
+}
+
+// Validate checks the structure of every aggregation in the map. See Validate()
+func (a *Aggregations) Validate() error {
+	return a.Walk(validator(a))
+}
+
+func validator(root aggregationSelector) WalkFunc {
+	return func(path []string, agg Aggregation) error {
+		if len(agg.GetSubNames()) > 0 && !isBucketAggregation(agg) {
+			return &PathError{Op: "Validate", Path: joinPath(path), Node: agg, Err: ErrAggIsNotInjectable}
+		}
+
+		if len(path) == 0 {
+			// buckets_path of the root can't be resolved: it has no siblings
+			return nil
+		}
+
+		for _, bucketsPath := range bucketsPathsOf(agg) {
+			if _, err := resolveBucketsPath(root, path, bucketsPath); err != nil {
+				return withPathPrefix("Validate", nil, err)
+			}
+		}
+
+		return nil
+	}
+}
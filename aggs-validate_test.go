@@ -0,0 +1,52 @@
+package aggretastic_test
+
+import (
+	"errors"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+
+	It("should accept subAggregations under buckets", func() {
+		agg := aggretastic.NewTermsAggregation().Field("country")
+		agg.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+		agg.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "revenue")
+		agg.Inject(aggretastic.NewDerivativeAggregation().BucketsPath("revenue"), "by_day", "growth")
+
+		Expect(aggretastic.Validate(agg)).To(Succeed())
+	})
+
+	It("should report the path of metric with subAggregations", func() {
+		agg := aggretastic.NewTermsAggregation().Field("country").SubAggregation("revenue",
+			aggretastic.NewSumAggregation().Field("price").SubAggregation("x", aggretastic.NewMaxAggregation().Field("y")),
+		)
+		aggs := aggretastic.Aggregations{"by_country": agg}
+
+		err := aggs.Validate()
+		Expect(errors.Is(err, aggretastic.ErrAggIsNotInjectable)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("by_country>revenue"))
+
+		var pathErr *aggretastic.PathError
+		Expect(errors.As(err, &pathErr)).To(BeTrue())
+		Expect(pathErr.Op).To(Equal("Validate"))
+		Expect(pathErr.Path).To(Equal([]string{"by_country", "revenue"}))
+		Expect(pathErr.Node).To(BeIdenticalTo(agg.Select("revenue")))
+
+		err = aggretastic.Validate(aggretastic.NewPercentilesAggregation().SubAggregation("x", aggretastic.NewMaxAggregation()))
+		Expect(errors.Is(err, aggretastic.ErrAggIsNotInjectable)).To(BeTrue())
+	})
+
+	It("should not allow to inject into metrics", func() {
+		agg := aggretastic.NewTermsAggregation().Field("country")
+		agg.Inject(aggretastic.NewCardinalityAggregation().Field("customer"), "customers")
+
+		_, err := agg.Inject(aggretastic.NewMaxAggregation().Field("y"), "customers", "x")
+		Expect(err).To(MatchError(aggretastic.ErrAggIsNotInjectable))
+
+		_, err = aggretastic.NewMatrixStatsAggregation().Inject(aggretastic.NewMaxAggregation().Field("y"), "x")
+		Expect(err).To(MatchError(aggretastic.ErrAggIsNotInjectable))
+	})
+})
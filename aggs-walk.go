@@ -0,0 +1,85 @@
+package aggretastic
+
+import "fmt"
+
+// ErrSkipSubtree is used as a return value from WalkFunc to indicate that
+// the subAggregations of the visited aggregation are to be skipped.
+// It is not returned as an error by any Walk function
+var ErrSkipSubtree = fmt.Errorf("skip this subtree")
+
+// WalkFunc is the type of the function called for each aggregation visited by Walk.
+// The path is relative to the aggregation Walk was started from (the starting aggregation itself has an empty path).
+// The path is a fresh slice on every call so it can be kept by the caller.
+//
+// If the function returns ErrSkipSubtree, Walk skips the subAggregations of the visited aggregation.
+// Any other error stops the walk and is returned by Walk
+type WalkFunc func(path []string, agg Aggregation) error
+
+// Walk walks the aggregation tree in pre-order: every aggregation is visited before its subAggregations.
+// The subAggregations are visited in their order.
+// Aggregations which can't have subAggregations (pipelines etc.) are visited as leafs
+func Walk(agg Aggregation, fn WalkFunc) error {
+	return walk([]string{}, agg, fn, false)
+}
+
+// WalkPostOrder walks the aggregation tree in post-order: every aggregation is visited after its subAggregations.
+// ErrSkipSubtree returned from fn has no effect here because the subtree is already visited
+func WalkPostOrder(agg Aggregation, fn WalkFunc) error {
+	return walk([]string{}, agg, fn, true)
+}
+
+// Walk walks every aggregation of the map in pre-order. The aggregations of the map are visited in order of their names.
+// The paths start with the name of aggregation in the map
+func (a *Aggregations) Walk(fn WalkFunc) error {
+	return a.walk(fn, false)
+}
+
+// WalkPostOrder walks every aggregation of the map in post-order. The aggregations of the map are visited in order of their names.
+// The paths start with the name of aggregation in the map
+func (a *Aggregations) WalkPostOrder(fn WalkFunc) error {
+	return a.walk(fn, true)
+}
+
+func (a *Aggregations) walk(fn WalkFunc, postOrder bool) error {
+	if a == nil {
+		return nil
+	}
+
+	for _, name := range sortedNames(*a) {
+		if err := walk([]string{name}, (*a)[name], fn, postOrder); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func walk(path []string, agg Aggregation, fn WalkFunc, postOrder bool) error {
+	if !postOrder {
+		if err := fn(joinPath(path), agg); err != nil {
+			if err == ErrSkipSubtree {
+				return nil
+			}
+			return err
+		}
+	}
+
+	for _, name := range agg.GetSubNames() {
+		subAgg := agg.Select(name)
+		if subAgg == nil {
+			continue
+		}
+
+		if err := walk(joinPath(path, name), subAgg, fn, postOrder); err != nil {
+			return err
+		}
+	}
+
+	if postOrder {
+		if err := fn(joinPath(path), agg); err != nil && err != ErrSkipSubtree {
+			return err
+		}
+	}
+
+	return nil
+}
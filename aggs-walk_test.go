@@ -0,0 +1,79 @@
+package aggretastic_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Walk", func() {
+
+	newTree := func() *aggretastic.TermsAggregation {
+		agg := aggretastic.NewTermsAggregation().Field("country")
+		agg.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+		agg.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "revenue")
+		agg.Inject(aggretastic.NewDerivativeAggregation().BucketsPath("revenue"), "by_day", "growth")
+		agg.Inject(aggretastic.NewMaxBucketAggregation().BucketsPath("by_day>revenue"), "best_day")
+		return agg
+	}
+
+	collect := func(visited *[]string) aggretastic.WalkFunc {
+		return func(path []string, agg aggretastic.Aggregation) error {
+			*visited = append(*visited, strings.Join(path, ">"))
+			return nil
+		}
+	}
+
+	It("should visit aggregations in pre-order", func() {
+		visited := make([]string, 0)
+		Expect(aggretastic.Walk(newTree(), collect(&visited))).To(Succeed())
+		Expect(visited).To(Equal([]string{"", "by_day", "by_day>revenue", "by_day>growth", "best_day"}))
+	})
+
+	It("should visit aggregations in post-order", func() {
+		visited := make([]string, 0)
+		Expect(aggretastic.WalkPostOrder(newTree(), collect(&visited))).To(Succeed())
+		Expect(visited).To(Equal([]string{"by_day>revenue", "by_day>growth", "by_day", "best_day", ""}))
+	})
+
+	It("should skip a subtree", func() {
+		visited := make([]string, 0)
+		err := aggretastic.Walk(newTree(), func(path []string, agg aggretastic.Aggregation) error {
+			visited = append(visited, strings.Join(path, ">"))
+			if _, ok := agg.(*aggretastic.DateHistogramAggregation); ok {
+				return aggretastic.ErrSkipSubtree
+			}
+			return nil
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(visited).To(Equal([]string{"", "by_day", "best_day"}))
+	})
+
+	It("should stop on error", func() {
+		stop := fmt.Errorf("stop")
+		visited := make([]string, 0)
+		err := aggretastic.Walk(newTree(), func(path []string, agg aggretastic.Aggregation) error {
+			visited = append(visited, strings.Join(path, ">"))
+			if len(path) == 2 {
+				return stop
+			}
+			return nil
+		})
+		Expect(err).To(Equal(stop))
+		Expect(visited).To(Equal([]string{"", "by_day", "by_day>revenue"}))
+	})
+
+	It("should walk the map of aggregations", func() {
+		aggs := aggretastic.Aggregations{
+			"b": newTree(),
+			"a": aggretastic.NewAvgBucketAggregation().BucketsPath("b>by_day>revenue"),
+		}
+
+		visited := make([]string, 0)
+		Expect(aggs.Walk(collect(&visited))).To(Succeed())
+		Expect(visited).To(Equal([]string{"a", "b", "b>by_day", "b>by_day>revenue", "b>by_day>growth", "b>best_day"}))
+	})
+})
@@ -0,0 +1,122 @@
+package aggretastic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Wrap inserts the bucket aggregation `parent` between an aggregation and its subAggregations.
+// The last element of path is the name of parent, the rest is the path of wrapped aggregation
+// (Wrap(agg, []string{"nested"}, parent) wraps the subAggregations of agg itself).
+//
+// Parent pipelines (bucket_script, derivative etc.) stay at their place because they need the buckets of wrapped aggregation,
+// the rest of subAggregations (incl. sibling pipelines) are moved into parent keeping their order.
+// Every buckets_path which now crosses parent is rewritten.
+// The new leaf paths are returned as Inject() does
+func Wrap(agg Aggregation, path []string, parent Aggregation) ([][]string, error) {
+	return newRestructure(agg, func(fn WalkFunc) error { return Walk(agg, fn) }).wrap(path, parent)
+}
+
+// Wrap inserts the bucket aggregation `parent` between an aggregation of the map and its subAggregations. See Wrap().
+// The path of one element wraps all the aggregations of the map
+func (a *Aggregations) Wrap(path []string, parent Aggregation) ([][]string, error) {
+	return newRestructure(a, a.Walk).wrap(path, parent)
+}
+
+func (r *restructure) wrap(path []string, parent Aggregation) ([][]string, error) {
+	if len(path) == 0 {
+		return nil, &PathError{Op: "Wrap", Err: ErrNoPath}
+	}
+	if !isBucketAggregation(parent) {
+		// only bucket aggregations can have subAggregations
+		return nil, &PathError{Op: "Wrap", Path: path, Node: parent, Err: ErrAggIsNotInjectable}
+	}
+	if existing := r.root.Select(path...); existing != nil {
+		return nil, &PathError{Op: "Wrap", Path: path, Node: existing, Err: ErrSubAggExists}
+	}
+
+	wrappedPath, name := path[:len(path)-1], path[len(path)-1]
+
+	var names []string
+	switch root := r.root.(type) {
+	case *Aggregations:
+		if len(wrappedPath) == 0 {
+			names = sortedNames(*root)
+		}
+	case Aggregation:
+		if len(wrappedPath) == 0 {
+			names = root.GetSubNames()
+		}
+	}
+
+	if len(wrappedPath) > 0 {
+		wrapped := r.root.Select(wrappedPath...)
+		if wrapped == nil {
+			return nil, &PathError{Op: "Wrap", Path: missingPath(r.root, wrappedPath), Err: ErrSubAggNotFound}
+		}
+		if !isBucketAggregation(wrapped) {
+			return nil, &PathError{Op: "Wrap", Path: wrappedPath, Node: wrapped, Err: ErrAggIsNotInjectable}
+		}
+		names = wrapped.GetSubNames()
+	}
+
+	moved := make(map[string]bool, len(names))
+	for _, subName := range names {
+		subPath := joinPath(wrappedPath, subName)
+		if sub := r.root.Select(subPath...); isPipelineAggregation(sub) && !isSiblingPipelineAggregation(sub) {
+			continue
+		}
+		if existing := parent.Select(subName); existing != nil {
+			return nil, &PathError{Op: "Wrap", Path: joinPath(path, subName), Node: existing, Err: ErrSubAggExists}
+		}
+		moved[subName] = true
+	}
+
+	rewrites, err := r.bucketsPathsRewrites(newWrapRelocation(wrappedPath, name, moved))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subName := range names {
+		if !moved[subName] {
+			continue
+		}
+		if _, err := parent.Inject(r.root.Pop(joinPath(wrappedPath, subName)...), subName); err != nil {
+			return nil, withPathPrefix("Wrap", path, err)
+		}
+	}
+
+	resultPaths, err := r.root.Inject(parent, path...)
+	if err != nil {
+		return nil, withPathPrefix("Wrap", nil, err)
+	}
+
+	rewrites.apply()
+	return resultPaths, nil
+}
+
+// newWrapRelocation inserts the name after the wrapped path into the paths of moved subAggregations
+func newWrapRelocation(wrappedPath []string, name string, moved map[string]bool) relocation {
+	isMoved := func(names []string) bool {
+		return len(names) > len(wrappedPath) && hasPathPrefix(names, wrappedPath) && moved[names[len(wrappedPath)]]
+	}
+
+	return relocation{
+		path: func(path []string) []string {
+			if !isMoved(path) {
+				return joinPath(path)
+			}
+			return joinPath(joinPath(wrappedPath, name), path[len(wrappedPath):]...)
+		},
+		chain: func(chain []BucketsPathElement) []BucketsPathElement {
+			if !isMoved(chainNames(chain)) {
+				return chain
+			}
+			wrapped := make([]BucketsPathElement, 0, len(chain)+1)
+			wrapped = append(wrapped, chain[:len(wrappedPath)]...)
+			wrapped = append(wrapped, BucketsPathElement{Name: name})
+			return append(wrapped, chain[len(wrappedPath):]...)
+		},
+		description: fmt.Sprintf("wrapping into %s", strings.Join(joinPath(wrappedPath, name), ">")),
+	}
+}
@@ -0,0 +1,96 @@
+package aggretastic_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aahainc/aggretastic"
+	"github.com/olivere/elastic/v7"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Wrap", func() {
+
+	var aggs aggretastic.Aggregations
+
+	sourceOf := func(path ...string) string {
+		src, err := aggs.Select(path...).Source()
+		Expect(err).ShouldNot(HaveOccurred())
+		b, err := json.Marshal(src)
+		Expect(err).ShouldNot(HaveOccurred())
+		return string(b)
+	}
+
+	BeforeEach(func() {
+		byCountry := aggretastic.NewTermsAggregation().Field("country")
+		byCountry.Inject(aggretastic.NewDateHistogramAggregation().Field("created_at"), "by_day")
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("price"), "by_day", "revenue")
+		byCountry.Inject(aggretastic.NewSumAggregation().Field("cost"), "by_day", "cost")
+		byCountry.Inject(aggretastic.NewBucketScriptAggregation().
+			AddBucketsPath("a", "revenue").AddBucketsPath("b", "cost").
+			Script(elastic.NewScript("params.a - params.b")), "by_day", "margin")
+		byCountry.Inject(aggretastic.NewBucketSortAggregation().Sort("revenue", false), "by_day", "top")
+		byCountry.Inject(aggretastic.NewMaxBucketAggregation().BucketsPath("by_day>revenue"), "best_day")
+		aggs = aggretastic.Aggregations{
+			"by_country":   byCountry,
+			"best_country": aggretastic.NewMaxBucketAggregation().BucketsPath("by_country>best_day"),
+		}
+	})
+
+	It("should wrap the subAggregations keeping parent pipelines", func() {
+		paid := aggretastic.NewFilterAggregation().Filter(elastic.NewTermQuery("paid", true))
+
+		paths, err := aggs.Wrap([]string{"by_country", "by_day", "paid"}, paid)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(Equal([][]string{
+			{"by_country", "by_day", "paid", "revenue"},
+			{"by_country", "by_day", "paid", "cost"},
+		}))
+		Expect(aggs.Select("by_country", "by_day").GetSubNames()).To(Equal([]string{"margin", "top", "paid"}))
+
+		Expect(sourceOf("by_country", "by_day", "margin")).To(MatchJSON(`{"bucket_script":{"buckets_path":{"a":"paid>revenue","b":"paid>cost"},"script":{"source":"params.a - params.b"}}}`))
+		Expect(sourceOf("by_country", "by_day", "top")).To(MatchJSON(`{"bucket_sort":{"sort":[{"paid>revenue":{"order":"desc"}}]}}`))
+		Expect(sourceOf("by_country", "best_day")).To(MatchJSON(`{"max_bucket":{"buckets_path":"by_day>paid>revenue"}}`))
+		Expect(aggs.Validate()).To(Succeed())
+	})
+
+	It("should move sibling pipelines with the wrapped subAggregations", func() {
+		nested := aggretastic.NewNestedAggregation().Path("orders")
+
+		paths, err := aggs.Wrap([]string{"by_country", "orders"}, nested)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(ContainElement([]string{"by_country", "orders", "best_day"}))
+		Expect(aggs.Select("by_country").GetSubNames()).To(Equal([]string{"orders"}))
+		Expect(sourceOf("by_country", "orders", "best_day")).To(MatchJSON(`{"max_bucket":{"buckets_path":"by_day>revenue"}}`))
+		Expect(sourceOf("best_country")).To(MatchJSON(`{"max_bucket":{"buckets_path":"by_country>orders>best_day"}}`))
+	})
+
+	It("should wrap the subAggregations of the root", func() {
+		agg := aggretastic.NewTermsAggregation().Field("country")
+		agg.Inject(aggretastic.NewSumAggregation().Field("price"), "revenue")
+		agg.Inject(aggretastic.NewDerivativeAggregation().BucketsPath("revenue"), "growth")
+
+		paths, err := aggretastic.Wrap(agg, []string{"paid"}, aggretastic.NewFilterAggregation().Filter(elastic.NewTermQuery("paid", true)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(Equal([][]string{{"paid", "revenue"}}))
+		Expect(agg.GetSubNames()).To(Equal([]string{"growth", "paid"}))
+
+		src, _ := agg.Select("growth").Source()
+		Expect(src).To(HaveKeyWithValue("derivative", HaveKeyWithValue("buckets_path", "paid>revenue")))
+	})
+
+	It("should check the paths", func() {
+		_, err := aggs.Wrap([]string{"by_country", "by_day"}, aggretastic.NewFilterAggregation())
+		Expect(errors.Is(err, aggretastic.ErrSubAggExists)).To(BeTrue())
+
+		_, err = aggs.Wrap([]string{"by_country", "x", "y"}, aggretastic.NewFilterAggregation())
+		Expect(errors.Is(err, aggretastic.ErrSubAggNotFound)).To(BeTrue())
+
+		_, err = aggs.Wrap([]string{"by_country", "by_day", "revenue", "x"}, aggretastic.NewFilterAggregation())
+		Expect(errors.Is(err, aggretastic.ErrAggIsNotInjectable)).To(BeTrue())
+
+		_, err = aggs.Wrap([]string{"by_country", "x"}, aggretastic.NewSumAggregation())
+		Expect(errors.Is(err, aggretastic.ErrAggIsNotInjectable)).To(BeTrue())
+	})
+})
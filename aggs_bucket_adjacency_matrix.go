@@ -32,7 +32,7 @@ func (a *AdjacencyMatrixAggregation) Filters(name string, filter elastic.Query)
 
 // SubAggregation adds a sub-aggregation to this aggregation.
 func (a *AdjacencyMatrixAggregation) SubAggregation(name string, subAggregation Aggregation) *AdjacencyMatrixAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -42,6 +42,21 @@ func (a *AdjacencyMatrixAggregation) Meta(metaData map[string]interface{}) *Adja
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *AdjacencyMatrixAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *AdjacencyMatrixAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.filters = cloneQueriesMap(a.filters)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *AdjacencyMatrixAggregation) Source() (interface{}, error) {
 	// Example:
@@ -74,16 +89,12 @@ func (a *AdjacencyMatrixAggregation) Source() (interface{}, error) {
 	adjacencyMatrix["filters"] = dict
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -24,7 +24,7 @@ func (a *ChildrenAggregation) Type(typ string) *ChildrenAggregation {
 }
 
 func (a *ChildrenAggregation) SubAggregation(name string, subAggregation Aggregation) *ChildrenAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -34,6 +34,20 @@ func (a *ChildrenAggregation) Meta(metaData map[string]interface{}) *ChildrenAgg
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *ChildrenAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *ChildrenAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *ChildrenAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -53,16 +67,12 @@ func (a *ChildrenAggregation) Source() (interface{}, error) {
 	opts["type"] = a.typ
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -47,7 +47,7 @@ func (a *CompositeAggregation) Sources(sources ...CompositeAggregationValuesSour
 
 // SubAggregations of this aggregation.
 func (a *CompositeAggregation) SubAggregation(name string, subAggregation Aggregation) *CompositeAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -57,6 +57,22 @@ func (a *CompositeAggregation) Meta(metaData map[string]interface{}) *CompositeA
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *CompositeAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *CompositeAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.after = cloneMap(a.after)
+	c.sources = cloneCompositeValuesSources(a.sources)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the serializable JSON for this aggregation.
 func (a *CompositeAggregation) Source() (interface{}, error) {
 	// Example:
@@ -101,16 +117,12 @@ func (a *CompositeAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
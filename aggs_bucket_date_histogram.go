@@ -55,7 +55,7 @@ func (a *DateHistogramAggregation) Missing(missing interface{}) *DateHistogramAg
 }
 
 func (a *DateHistogramAggregation) SubAggregation(name string, subAggregation Aggregation) *DateHistogramAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -220,6 +220,24 @@ func (a *DateHistogramAggregation) ExtendedBoundsMax(max interface{}) *DateHisto
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *DateHistogramAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *DateHistogramAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.script = cloneScript(a.script)
+	c.missing = cloneValue(a.missing)
+	c.extendedBoundsMin = cloneValue(a.extendedBoundsMin)
+	c.extendedBoundsMax = cloneValue(a.extendedBoundsMax)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *DateHistogramAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -297,16 +315,12 @@ func (a *DateHistogramAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
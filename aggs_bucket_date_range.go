@@ -50,7 +50,7 @@ func (a *DateRangeAggregation) Script(script *elastic.Script) *DateRangeAggregat
 }
 
 func (a *DateRangeAggregation) SubAggregation(name string, subAggregation Aggregation) *DateRangeAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -140,6 +140,22 @@ func (a *DateRangeAggregation) GtWithKey(key string, from interface{}) *DateRang
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *DateRangeAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *DateRangeAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.script = cloneScript(a.script)
+	c.entries = append(make([]DateRangeAggregationEntry, 0, len(a.entries)), a.entries...)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *DateRangeAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -232,16 +248,12 @@ func (a *DateRangeAggregation) Source() (interface{}, error) {
 	opts["ranges"] = ranges
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
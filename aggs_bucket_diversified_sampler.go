@@ -29,7 +29,7 @@ func NewDiversifiedSamplerAggregation() *DiversifiedSamplerAggregation {
 }
 
 func (a *DiversifiedSamplerAggregation) SubAggregation(name string, subAggregation Aggregation) *DiversifiedSamplerAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -66,6 +66,21 @@ func (a *DiversifiedSamplerAggregation) ExecutionHint(hint string) *DiversifiedS
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *DiversifiedSamplerAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *DiversifiedSamplerAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.script = cloneScript(a.script)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *DiversifiedSamplerAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -106,16 +121,12 @@ func (a *DiversifiedSamplerAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -22,7 +22,7 @@ func NewFilterAggregation() *FilterAggregation {
 }
 
 func (a *FilterAggregation) SubAggregation(name string, subAggregation Aggregation) *FilterAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -37,6 +37,20 @@ func (a *FilterAggregation) Filter(filter elastic.Query) *FilterAggregation {
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *FilterAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *FilterAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *FilterAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -56,16 +70,12 @@ func (a *FilterAggregation) Source() (interface{}, error) {
 	source["filter"] = src
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
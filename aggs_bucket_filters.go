@@ -59,7 +59,7 @@ func (a *FiltersAggregation) FilterWithName(name string, filter elastic.Query) *
 
 // SubAggregation adds a sub-aggregation to this aggregation.
 func (a *FiltersAggregation) SubAggregation(name string, subAggregation Aggregation) *FiltersAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -69,6 +69,22 @@ func (a *FiltersAggregation) Meta(metaData map[string]interface{}) *FiltersAggre
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *FiltersAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *FiltersAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.unnamedFilters = append(make([]elastic.Query, 0, len(a.unnamedFilters)), a.unnamedFilters...)
+	c.namedFilters = cloneQueriesMap(a.namedFilters)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 // If the aggregation is invalid, an error is returned. This may e.g. happen
 // if you mixed named and unnamed filters.
@@ -119,16 +135,12 @@ func (a *FiltersAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -55,7 +55,7 @@ func (a *GeoDistanceAggregation) Point(latLon string) *GeoDistanceAggregation {
 }
 
 func (a *GeoDistanceAggregation) SubAggregation(name string, subAggregation Aggregation) *GeoDistanceAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -104,6 +104,21 @@ func (a *GeoDistanceAggregation) BetweenWithKey(key string, from, to interface{}
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *GeoDistanceAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *GeoDistanceAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.ranges = append(make([]geoDistAggRange, 0, len(a.ranges)), a.ranges...)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *GeoDistanceAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -176,16 +191,12 @@ func (a *GeoDistanceAggregation) Source() (interface{}, error) {
 	opts["ranges"] = ranges
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -44,7 +44,7 @@ func (a *GeoHashGridAggregation) ShardSize(shardSize int) *GeoHashGridAggregatio
 }
 
 func (a *GeoHashGridAggregation) SubAggregation(name string, subAggregation Aggregation) *GeoHashGridAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -53,6 +53,21 @@ func (a *GeoHashGridAggregation) Meta(metaData map[string]interface{}) *GeoHashG
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *GeoHashGridAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *GeoHashGridAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.precision = cloneValue(a.precision)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *GeoHashGridAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -87,16 +102,12 @@ func (a *GeoHashGridAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	if len(a.meta) > 0 {
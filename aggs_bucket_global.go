@@ -19,7 +19,7 @@ func NewGlobalAggregation() *GlobalAggregation {
 }
 
 func (a *GlobalAggregation) SubAggregation(name string, subAggregation Aggregation) *GlobalAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -29,6 +29,20 @@ func (a *GlobalAggregation) Meta(metaData map[string]interface{}) *GlobalAggrega
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *GlobalAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *GlobalAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *GlobalAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -48,16 +62,12 @@ func (a *GlobalAggregation) Source() (interface{}, error) {
 	source["global"] = opts
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -48,7 +48,7 @@ func (a *HistogramAggregation) Missing(missing interface{}) *HistogramAggregatio
 }
 
 func (a *HistogramAggregation) SubAggregation(name string, subAggregation Aggregation) *HistogramAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -182,6 +182,22 @@ func (a *HistogramAggregation) Offset(offset float64) *HistogramAggregation {
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *HistogramAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *HistogramAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.script = cloneScript(a.script)
+	c.missing = cloneValue(a.missing)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *HistogramAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -244,16 +260,12 @@ func (a *HistogramAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -35,7 +35,7 @@ func (a *IPRangeAggregation) Field(field string) *IPRangeAggregation {
 }
 
 func (a *IPRangeAggregation) SubAggregation(name string, subAggregation Aggregation) *IPRangeAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -120,6 +120,21 @@ func (a *IPRangeAggregation) GtWithKey(key, from string) *IPRangeAggregation {
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *IPRangeAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *IPRangeAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.entries = append(make([]IPRangeAggregationEntry, 0, len(a.entries)), a.entries...)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *IPRangeAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -173,16 +188,12 @@ func (a *IPRangeAggregation) Source() (interface{}, error) {
 	opts["ranges"] = ranges
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
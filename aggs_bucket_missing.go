@@ -28,7 +28,7 @@ func (a *MissingAggregation) Field(field string) *MissingAggregation {
 }
 
 func (a *MissingAggregation) SubAggregation(name string, subAggregation Aggregation) *MissingAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -38,6 +38,20 @@ func (a *MissingAggregation) Meta(metaData map[string]interface{}) *MissingAggre
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *MissingAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *MissingAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *MissingAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -58,16 +72,12 @@ func (a *MissingAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
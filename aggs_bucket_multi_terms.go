@@ -39,7 +39,7 @@ func (a *MultiTermsAggregation) Term(field string, missingArg ...interface{}) *M
 }
 
 func (a *MultiTermsAggregation) SubAggregation(name string, subAggregation Aggregation) *MultiTermsAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -153,6 +153,25 @@ func (a *MultiTermsAggregation) ShowTermDocCountError(showTermDocCountError bool
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *MultiTermsAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *MultiTermsAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.terms = make([]*MultiTermsField, len(a.terms))
+	for i, term := range a.terms {
+		c.terms[i] = &MultiTermsField{field: term.field, missing: cloneValue(term.missing)}
+	}
+	c.order = cloneTermsOrder(a.order)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *MultiTermsAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -212,16 +231,12 @@ func (a *MultiTermsAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -18,7 +18,7 @@ func NewNestedAggregation() *NestedAggregation {
 }
 
 func (a *NestedAggregation) SubAggregation(name string, subAggregation Aggregation) *NestedAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -33,6 +33,20 @@ func (a *NestedAggregation) Path(path string) *NestedAggregation {
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *NestedAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *NestedAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *NestedAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -59,16 +73,12 @@ func (a *NestedAggregation) Source() (interface{}, error) {
 	opts["path"] = a.path
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -56,7 +56,7 @@ func (a *RangeAggregation) Missing(missing interface{}) *RangeAggregation {
 }
 
 func (a *RangeAggregation) SubAggregation(name string, subAggregation Aggregation) *RangeAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -136,6 +136,23 @@ func (a *RangeAggregation) GtWithKey(key string, from interface{}) *RangeAggrega
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *RangeAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *RangeAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.script = cloneScript(a.script)
+	c.missing = cloneValue(a.missing)
+	c.entries = append(make([]rangeAggregationEntry, 0, len(a.entries)), a.entries...)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *RangeAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -224,16 +241,12 @@ func (a *RangeAggregation) Source() (interface{}, error) {
 	opts["ranges"] = ranges
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -33,7 +33,7 @@ func (a *SignificantTermsAggregation) Field(field string) *SignificantTermsAggre
 }
 
 func (a *SignificantTermsAggregation) SubAggregation(name string, subAggregation Aggregation) *SignificantTermsAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -78,6 +78,21 @@ func (a *SignificantTermsAggregation) SignificanceHeuristic(heuristic Significan
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *SignificantTermsAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *SignificantTermsAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.significanceHeuristic = cloneSignificanceHeuristic(a.significanceHeuristic)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *SignificantTermsAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -134,16 +149,12 @@ func (a *SignificantTermsAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -32,7 +32,7 @@ func (a *SignificantTextAggregation) Field(field string) *SignificantTextAggrega
 }
 
 func (a *SignificantTextAggregation) SubAggregation(name string, subAggregation Aggregation) *SignificantTextAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -142,6 +142,27 @@ func (a *SignificantTextAggregation) NumPartitions(n int) *SignificantTextAggreg
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *SignificantTextAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *SignificantTextAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.sourceFieldNames = cloneStrings(a.sourceFieldNames)
+	c.includeExclude = cloneIncludeExclude(a.includeExclude)
+	if a.bucketCountThresholds != nil {
+		thresholds := *a.bucketCountThresholds
+		c.bucketCountThresholds = &thresholds
+	}
+	c.significanceHeuristic = cloneSignificanceHeuristic(a.significanceHeuristic)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *SignificantTextAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -224,16 +245,12 @@ func (a *SignificantTextAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
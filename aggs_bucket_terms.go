@@ -51,7 +51,7 @@ func (a *TermsAggregation) Missing(missing interface{}) *TermsAggregation {
 }
 
 func (a *TermsAggregation) SubAggregation(name string, subAggregation Aggregation) *TermsAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -229,6 +229,24 @@ func (a *TermsAggregation) ShowTermDocCountError(showTermDocCountError bool) *Te
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *TermsAggregation) Clone() Aggregation {
+	return a.cloneWith(a.tree.cloneFor)
+}
+
+// cloneWith returns a copy of the aggregation whose tree is copied by cloneTree
+func (a *TermsAggregation) cloneWith(cloneTree treeCloner) Aggregation {
+	c := *a
+	c.tree = cloneTree(&c)
+	c.script = cloneScript(a.script)
+	c.missing = cloneValue(a.missing)
+	c.includeExclude = cloneIncludeExclude(a.includeExclude)
+	c.order = cloneTermsOrder(a.order)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *TermsAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -321,16 +339,12 @@ func (a *TermsAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -56,7 +56,7 @@ func (a *MatrixStatsAggregation) ValueType(valueType interface{}) *MatrixStatsAg
 }
 
 func (a *MatrixStatsAggregation) SubAggregation(name string, subAggregation Aggregation) *MatrixStatsAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -66,6 +66,18 @@ func (a *MatrixStatsAggregation) Meta(metaData map[string]interface{}) *MatrixSt
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *MatrixStatsAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.fields = cloneStrings(a.fields)
+	c.missing = cloneValue(a.missing)
+	c.valueType = cloneValue(a.valueType)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the JSON to serialize into the request, or an error.
 func (a *MatrixStatsAggregation) Source() (interface{}, error) {
 	// Example:
@@ -103,16 +115,12 @@ func (a *MatrixStatsAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -41,7 +41,7 @@ func (a *AvgAggregation) Format(format string) *AvgAggregation {
 }
 
 func (a *AvgAggregation) SubAggregation(name string, subAggregation Aggregation) *AvgAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -51,6 +51,16 @@ func (a *AvgAggregation) Meta(metaData map[string]interface{}) *AvgAggregation {
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *AvgAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *AvgAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -81,16 +91,12 @@ func (a *AvgAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -41,7 +41,7 @@ func (a *CardinalityAggregation) Format(format string) *CardinalityAggregation {
 }
 
 func (a *CardinalityAggregation) SubAggregation(name string, subAggregation Aggregation) *CardinalityAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -61,6 +61,16 @@ func (a *CardinalityAggregation) Rehash(rehash bool) *CardinalityAggregation {
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *CardinalityAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *CardinalityAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -99,16 +109,12 @@ func (a *CardinalityAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -37,7 +37,7 @@ func (a *GeoBoundsAggregation) WrapLongitude(wrapLongitude bool) *GeoBoundsAggre
 }
 
 func (a *GeoBoundsAggregation) SubAggregation(name string, subAggregation Aggregation) *GeoBoundsAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -47,6 +47,16 @@ func (a *GeoBoundsAggregation) Meta(metaData map[string]interface{}) *GeoBoundsA
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *GeoBoundsAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *GeoBoundsAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -84,16 +94,12 @@ func (a *GeoBoundsAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
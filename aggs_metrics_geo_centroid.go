@@ -31,7 +31,7 @@ func (a *GeoCentroidAggregation) Script(script *elastic.Script) *GeoCentroidAggr
 }
 
 func (a *GeoCentroidAggregation) SubAggregation(name string, subAggregation Aggregation) *GeoCentroidAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -41,6 +41,16 @@ func (a *GeoCentroidAggregation) Meta(metaData map[string]interface{}) *GeoCentr
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *GeoCentroidAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *GeoCentroidAggregation) Source() (interface{}, error) {
 	// Example:
 	// {
@@ -74,16 +84,12 @@ func (a *GeoCentroidAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -40,7 +40,7 @@ func (a *MaxAggregation) Format(format string) *MaxAggregation {
 }
 
 func (a *MaxAggregation) SubAggregation(name string, subAggregation Aggregation) *MaxAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -49,6 +49,17 @@ func (a *MaxAggregation) Meta(metaData map[string]interface{}) *MaxAggregation {
 	a.meta = metaData
 	return a
 }
+
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *MaxAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *MaxAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -78,16 +89,12 @@ func (a *MaxAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
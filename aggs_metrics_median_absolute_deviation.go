@@ -47,7 +47,7 @@ func (a *MedianAbsoluteDeviationAggregation) Missing(missing interface{}) *Media
 }
 
 func (a *MedianAbsoluteDeviationAggregation) SubAggregation(name string, subAggregation Aggregation) *MedianAbsoluteDeviationAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -57,6 +57,17 @@ func (a *MedianAbsoluteDeviationAggregation) Meta(metaData map[string]interface{
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *MedianAbsoluteDeviationAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.missing = cloneValue(a.missing)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *MedianAbsoluteDeviationAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -89,16 +100,12 @@ func (a *MedianAbsoluteDeviationAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -39,7 +39,7 @@ func (a *PercentileRanksAggregation) Format(format string) *PercentileRanksAggre
 }
 
 func (a *PercentileRanksAggregation) SubAggregation(name string, subAggregation Aggregation) *PercentileRanksAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -64,6 +64,17 @@ func (a *PercentileRanksAggregation) Estimator(estimator string) *PercentileRank
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *PercentileRanksAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.values = cloneFloat64s(a.values)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *PercentileRanksAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -109,16 +120,12 @@ func (a *PercentileRanksAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
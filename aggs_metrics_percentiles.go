@@ -44,7 +44,7 @@ func (a *PercentilesAggregation) Format(format string) *PercentilesAggregation {
 }
 
 func (a *PercentilesAggregation) SubAggregation(name string, subAggregation Aggregation) *PercentilesAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -69,6 +69,17 @@ func (a *PercentilesAggregation) Estimator(estimator string) *PercentilesAggrega
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *PercentilesAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.percentiles = cloneFloat64s(a.percentiles)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *PercentilesAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -113,16 +124,12 @@ func (a *PercentilesAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -56,6 +56,20 @@ func (a *ScriptedMetricAggregation) Meta(metaData map[string]interface{}) *Scrip
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *ScriptedMetricAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.initScript = cloneScript(a.initScript)
+	c.mapScript = cloneScript(a.mapScript)
+	c.combineScript = cloneScript(a.combineScript)
+	c.reduceScript = cloneScript(a.reduceScript)
+	c.params = cloneMap(a.params)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *ScriptedMetricAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
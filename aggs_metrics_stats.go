@@ -39,7 +39,7 @@ func (a *StatsAggregation) Format(format string) *StatsAggregation {
 }
 
 func (a *StatsAggregation) SubAggregation(name string, subAggregation Aggregation) *StatsAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -49,6 +49,16 @@ func (a *StatsAggregation) Meta(metaData map[string]interface{}) *StatsAggregati
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *StatsAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *StatsAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -78,16 +88,12 @@ func (a *StatsAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -42,7 +42,7 @@ func (a *ValueCountAggregation) Format(format string) *ValueCountAggregation {
 }
 
 func (a *ValueCountAggregation) SubAggregation(name string, subAggregation Aggregation) *ValueCountAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -52,6 +52,16 @@ func (a *ValueCountAggregation) Meta(metaData map[string]interface{}) *ValueCoun
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *ValueCountAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *ValueCountAggregation) Source() (interface{}, error) {
 	// Example:
 	//	{
@@ -81,16 +91,12 @@ func (a *ValueCountAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
@@ -11,19 +11,17 @@ import "github.com/olivere/elastic/v7"
 type WeightedAvgAggregation struct {
 	*tree
 
-	fields          map[string]*MultiValuesSourceFieldConfig
-	valueType       string
-	format          string
-	value           *MultiValuesSourceFieldConfig
-	weight          *MultiValuesSourceFieldConfig
-	subAggregations map[string]Aggregation
-	meta            map[string]interface{}
+	fields    map[string]*MultiValuesSourceFieldConfig
+	valueType string
+	format    string
+	value     *MultiValuesSourceFieldConfig
+	weight    *MultiValuesSourceFieldConfig
+	meta      map[string]interface{}
 }
 
 func NewWeightedAvgAggregation() *WeightedAvgAggregation {
 	a := &WeightedAvgAggregation{
-		fields:          make(map[string]*MultiValuesSourceFieldConfig),
-		subAggregations: make(map[string]Aggregation),
+		fields: make(map[string]*MultiValuesSourceFieldConfig),
 	}
 	a.tree = nilAggregationTree(a)
 
@@ -56,7 +54,7 @@ func (a *WeightedAvgAggregation) Weight(weight *MultiValuesSourceFieldConfig) *W
 }
 
 func (a *WeightedAvgAggregation) SubAggregation(name string, subAggregation Aggregation) *WeightedAvgAggregation {
-	a.subAggregations[name] = subAggregation
+	a.subAggregations.Set(name, subAggregation)
 	return a
 }
 
@@ -66,6 +64,21 @@ func (a *WeightedAvgAggregation) Meta(metaData map[string]interface{}) *Weighted
 	return a
 }
 
+// Clone returns a deep copy of the aggregation with all its subAggregations
+func (a *WeightedAvgAggregation) Clone() Aggregation {
+	c := *a
+	c.tree = a.tree.cloneFor(&c)
+	c.fields = make(map[string]*MultiValuesSourceFieldConfig, len(a.fields))
+	for name, config := range a.fields {
+		c.fields[name] = cloneFieldConfig(config)
+	}
+	c.value = cloneFieldConfig(a.value)
+	c.weight = cloneFieldConfig(a.weight)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 func (a *WeightedAvgAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
 	opts := make(map[string]interface{})
@@ -108,16 +121,12 @@ func (a *WeightedAvgAggregation) Source() (interface{}, error) {
 	}
 
 	// AggregationBuilder (SubAggregations)
-	if len(a.subAggregations) > 0 {
-		aggsMap := make(map[string]interface{})
-		source["aggregations"] = aggsMap
-		for name, aggregate := range a.subAggregations {
-			src, err := aggregate.Source()
-			if err != nil {
-				return nil, err
-			}
-			aggsMap[name] = src
+	if a.subAggregations.Len() > 0 {
+		aggsSrc, err := a.subAggregations.Source()
+		if err != nil {
+			return nil, err
 		}
+		source["aggregations"] = aggsSrc
 	}
 
 	// Add Meta data if available
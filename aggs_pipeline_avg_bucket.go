@@ -64,6 +64,16 @@ func (a *AvgBucketAggregation) BucketsPath(bucketsPaths ...string) *AvgBucketAgg
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *AvgBucketAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.bucketsPaths = cloneStrings(a.bucketsPaths)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *AvgBucketAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
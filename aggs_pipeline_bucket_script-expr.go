@@ -0,0 +1,424 @@
+package aggretastic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/olivere/elastic/v7"
+)
+
+var ErrInvalidExpression = fmt.Errorf("invalid bucket script expression")
+
+// exprFunctions are the functions allowed in expressions with their Painless names and arities.
+// They can be called by the short name (e.g. "max") or by the Painless one ("Math.max")
+var exprFunctions = map[string]struct {
+	painless string
+	arity    int
+	// long is set for the functions returning long: their result is cast to double
+	long bool
+}{
+	"abs":    {"Math.abs", 1, false},
+	"round":  {"Math.round", 1, true},
+	"floor":  {"Math.floor", 1, false},
+	"ceil":   {"Math.ceil", 1, false},
+	"sqrt":   {"Math.sqrt", 1, false},
+	"exp":    {"Math.exp", 1, false},
+	"log":    {"Math.log", 1, false},
+	"log10":  {"Math.log10", 1, false},
+	"signum": {"Math.signum", 1, false},
+	"min":    {"Math.min", 2, false},
+	"max":    {"Math.max", 2, false},
+	"pow":    {"Math.pow", 2, false},
+}
+
+// exprConstants are the named constants allowed in expressions
+var exprConstants = map[string]string{
+	"Math.PI": "Math.PI",
+	"Math.E":  "Math.E",
+}
+
+// BucketScriptExpr compiles the arithmetic expression into bucket_script aggregation, e.g.
+//
+//	BucketScriptExpr("(revenue.value - cost.value) / orders._count * 100")
+//
+// The expression consists of numbers, buckets paths (they become the variables of buckets_path),
+// operators + - * / %, parentheses and functions: abs, round, floor, ceil, sqrt, exp, log, log10, signum, min, max, pow
+// (the "Math." prefix is optional), constants Math.PI and Math.E.
+// The buckets paths with other characters are quoted by backticks: "`pre-tax>sum.value` * 0.8",
+// otherwise "pre-tax" is the subtraction.
+// The expression must refer at least one buckets path.
+// The Painless script is generated from the parsed expression only, so nothing else gets into it
+func BucketScriptExpr(expr string) (*BucketScriptAggregation, error) {
+	p := &exprParser{src: expr, vars: make(map[string]string), paths: make(map[string]string)}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	script, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != exprEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	if len(p.vars) == 0 {
+		// Elasticsearch requires buckets_path of bucket_script
+		return nil, p.errorf("no buckets paths")
+	}
+
+	return newBucketScriptAggregation(p.vars, elastic.NewScript(script.src)), nil
+}
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprNumber
+	exprIdent
+	exprQuoted
+	exprOperator
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int
+}
+
+// exprResult is the Painless source of parsed subexpression with the precedence of its top operator
+type exprResult struct {
+	src  string
+	prec int
+}
+
+const (
+	precAdditive = iota + 1
+	precMultiplicative
+	precUnary
+	precPrimary
+)
+
+type exprParser struct {
+	src string
+	pos int
+	tok exprToken
+
+	// vars are the variables of buckets_path by their names, paths are the names by the buckets paths
+	vars  BucketsPath
+	paths map[string]string
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at %d of %q", ErrInvalidExpression, fmt.Sprintf(format, args...), p.tok.pos, p.src)
+}
+
+// next reads the next token
+func (p *exprParser) next() error {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+
+	start := p.pos
+	if p.pos == len(p.src) {
+		p.tok = exprToken{kind: exprEOF, pos: start}
+		return nil
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case isDigit(c) || c == '.' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]):
+		p.scanNumber()
+		p.tok = exprToken{kind: exprNumber, text: p.src[start:p.pos], pos: start}
+	case isIdentStart(c):
+		if err := p.scanIdent(); err != nil {
+			return err
+		}
+		p.tok = exprToken{kind: exprIdent, text: p.src[start:p.pos], pos: start}
+	case c == '`':
+		end := strings.IndexByte(p.src[p.pos+1:], '`')
+		if end < 0 {
+			p.tok = exprToken{pos: start}
+			return p.errorf("unterminated quoted path")
+		}
+		p.pos += end + 2
+		p.tok = exprToken{kind: exprQuoted, text: p.src[start+1 : p.pos-1], pos: start}
+	case strings.IndexByte("+-*/%(),", c) >= 0:
+		p.pos++
+		p.tok = exprToken{kind: exprOperator, text: string(c), pos: start}
+	default:
+		p.tok = exprToken{pos: start}
+		return p.errorf("unexpected %q", c)
+	}
+
+	return nil
+}
+
+func (p *exprParser) scanNumber() {
+	for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		exp := p.pos + 1
+		if exp < len(p.src) && (p.src[exp] == '+' || p.src[exp] == '-') {
+			exp++
+		}
+		if exp < len(p.src) && isDigit(p.src[exp]) {
+			p.pos = exp
+			for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+				p.pos++
+			}
+		}
+	}
+}
+
+// scanIdent reads a name of function or a buckets path: names separated by '>' and '.' with optional keys in brackets
+func (p *exprParser) scanIdent() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case isIdentStart(c) || isDigit(c):
+			p.pos++
+		case c == '.' || c == '>':
+			// the percentiles are selected by numbers e.g. "load_time.99"
+			if p.pos+1 >= len(p.src) || !isIdentStart(p.src[p.pos+1]) && !isDigit(p.src[p.pos+1]) {
+				return nil
+			}
+			p.pos++
+		case c == '[':
+			end := strings.IndexByte(p.src[p.pos:], ']')
+			if end < 0 {
+				p.tok = exprToken{pos: p.pos}
+				return p.errorf("unterminated key")
+			}
+			p.pos += end + 1
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *exprParser) isOperator(ops string) bool {
+	return p.tok.kind == exprOperator && strings.Contains(ops, p.tok.text)
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.isOperator(op) {
+		if p.tok.kind == exprEOF {
+			return p.errorf("%q is expected", op)
+		}
+		return p.errorf("%q is expected instead of %q", op, p.tok.text)
+	}
+	return p.next()
+}
+
+// parseExpr parses the additive expression: term (('+' | '-') term)*
+func (p *exprParser) parseExpr() (exprResult, error) {
+	return p.parseBinary(precAdditive, "+-", p.parseTerm)
+}
+
+// parseTerm parses the multiplicative expression: unary (('*' | '/' | '%') unary)*
+func (p *exprParser) parseTerm() (exprResult, error) {
+	return p.parseBinary(precMultiplicative, "*/%", p.parseUnary)
+}
+
+func (p *exprParser) parseBinary(prec int, ops string, operand func() (exprResult, error)) (exprResult, error) {
+	left, err := operand()
+	if err != nil {
+		return left, err
+	}
+
+	for p.isOperator(ops) {
+		op := p.tok.text
+		if err := p.next(); err != nil {
+			return left, err
+		}
+
+		right, err := operand()
+		if err != nil {
+			return right, err
+		}
+
+		// the operators are left-associative: the right operand of the same precedence needs parentheses
+		left = exprResult{src: parenthesize(left, prec-1) + " " + op + " " + parenthesize(right, prec), prec: prec}
+	}
+
+	return left, nil
+}
+
+// parseUnary parses the signed operand: ('-' | '+') unary | primary
+func (p *exprParser) parseUnary() (exprResult, error) {
+	if !p.isOperator("+-") {
+		return p.parsePrimary()
+	}
+
+	op := p.tok.text
+	if err := p.next(); err != nil {
+		return exprResult{}, err
+	}
+
+	operand, err := p.parseUnary()
+	if err != nil || op == "+" {
+		return operand, err
+	}
+
+	// "--x" is a decrement in Painless so the signed operands are always parenthesized
+	return exprResult{src: "-" + parenthesize(operand, precUnary), prec: precUnary}, nil
+}
+
+// parsePrimary parses number | constant | function '(' args ')' | buckets path | '`' buckets path '`' | '(' expr ')'
+func (p *exprParser) parsePrimary() (exprResult, error) {
+	tok := p.tok
+
+	switch tok.kind {
+	case exprNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return exprResult{}, p.errorf("invalid number %q", tok.text)
+		}
+		return exprResult{src: formatPainlessDouble(value), prec: precPrimary}, p.next()
+
+	case exprIdent:
+		if err := p.next(); err != nil {
+			return exprResult{}, err
+		}
+		if p.isOperator("(") {
+			return p.parseCall(tok)
+		}
+		if constant, ok := exprConstants[tok.text]; ok {
+			return exprResult{src: constant, prec: precPrimary}, nil
+		}
+		return p.variable(tok)
+
+	case exprQuoted:
+		if err := p.next(); err != nil {
+			return exprResult{}, err
+		}
+		return p.variable(tok)
+
+	case exprOperator:
+		if tok.text == "(" {
+			if err := p.next(); err != nil {
+				return exprResult{}, err
+			}
+			result, err := p.parseExpr()
+			if err != nil {
+				return result, err
+			}
+			return result, p.expect(")")
+		}
+		return exprResult{}, p.errorf("unexpected %q", tok.text)
+	}
+
+	return exprResult{}, p.errorf("unexpected end of expression")
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprResult, error) {
+	fn, ok := exprFunctions[strings.TrimPrefix(name.text, "Math.")]
+	if !ok {
+		p.tok = name
+		return exprResult{}, p.errorf("unknown function %q", name.text)
+	}
+
+	if err := p.next(); err != nil {
+		return exprResult{}, err
+	}
+
+	args := make([]string, 0, fn.arity)
+	for !p.isOperator(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return exprResult{}, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return arg, err
+		}
+		args = append(args, arg.src)
+	}
+
+	if len(args) != fn.arity {
+		p.tok = name
+		return exprResult{}, p.errorf("%s takes %d arguments, got %d", name.text, fn.arity, len(args))
+	}
+
+	call := fn.painless + "(" + strings.Join(args, ", ") + ")"
+	if fn.long {
+		return exprResult{src: "(double) " + call, prec: precUnary}, p.next()
+	}
+	return exprResult{src: call, prec: precPrimary}, p.next()
+}
+
+// variable adds the buckets path to the variables of bucket script
+func (p *exprParser) variable(tok exprToken) (exprResult, error) {
+	if _, err := ParseBucketsPath(tok.text); err != nil {
+		p.tok = tok
+		return exprResult{}, p.errorf("invalid buckets path %q", tok.text)
+	}
+
+	name, ok := p.paths[tok.text]
+	if !ok {
+		name = p.variableName(tok.text)
+		p.paths[tok.text] = name
+		p.vars[name] = tok.text
+	}
+
+	return exprResult{src: "params." + name, prec: precPrimary}, nil
+}
+
+// variableName turns the buckets path into a unique identifier: "orders._count" is "orders__count", "1x" is "_1x"
+func (p *exprParser) variableName(path string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= unicode.MaxASCII && (isIdentStart(byte(r)) || isDigit(byte(r))) {
+			return r
+		}
+		return '_'
+	}, path)
+	if !isIdentStart(name[0]) {
+		// the quoted paths may start with a digit e.g. "`1x`"
+		name = "_" + name
+	}
+
+	candidate := name
+	for i := 2; ; i++ {
+		if _, ok := p.vars[candidate]; !ok {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+}
+
+// parenthesize wraps the source of subexpression if its precedence isn't higher than the given one
+func parenthesize(r exprResult, prec int) string {
+	if r.prec <= prec {
+		return "(" + r.src + ")"
+	}
+	return r.src
+}
+
+// formatPainlessDouble formats the number as double literal so integer division never happens
+// (the other operands are the params and the functions which are doubles too)
+func formatPainlessDouble(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
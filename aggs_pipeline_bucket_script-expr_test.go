@@ -0,0 +1,123 @@
+package aggretastic_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BucketScriptExpr", func() {
+
+	sourceOf := func(expr string) string {
+		agg, err := aggretastic.BucketScriptExpr(expr)
+		Expect(err).NotTo(HaveOccurred())
+		src, err := agg.Source()
+		Expect(err).NotTo(HaveOccurred())
+		b, err := json.Marshal(src)
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	scriptOf := func(expr string) string {
+		var src struct {
+			BucketScript struct {
+				Script struct {
+					Source string `json:"source"`
+				} `json:"script"`
+			} `json:"bucket_script"`
+		}
+		Expect(json.Unmarshal([]byte(sourceOf(expr)), &src)).To(Succeed())
+		return src.BucketScript.Script.Source
+	}
+
+	It("should compile arithmetic with buckets path variables", func() {
+		Expect(sourceOf("(revenue.value - cost.value) / orders._count * 100")).To(MatchJSON(`{
+			"bucket_script": {
+				"buckets_path": {"revenue_value": "revenue.value", "cost_value": "cost.value", "orders__count": "orders._count"},
+				"script": {"source": "(params.revenue_value - params.cost_value) / params.orders__count * 100.0"}
+			}
+		}`))
+	})
+
+	It("should keep the meaning of parentheses and signs", func() {
+		Expect(scriptOf("a - (b - c)")).To(Equal("params.a - (params.b - params.c)"))
+		Expect(scriptOf("(a - b) - c")).To(Equal("params.a - params.b - params.c"))
+		Expect(scriptOf("a / (b * c) % 2")).To(Equal("params.a / (params.b * params.c) % 2.0"))
+		Expect(scriptOf("--a + +b")).To(Equal("-(-params.a) + params.b"))
+		Expect(scriptOf("-(a + b) * 1.5e3")).To(Equal("-(params.a + params.b) * 1500.0"))
+		Expect(scriptOf("a * (1 / 2)")).To(Equal("params.a * (1.0 / 2.0)"))
+	})
+
+	It("should compile functions and constants", func() {
+		expr := "round(max(revenue, 0) / Math.max(orders>_count, 1)) + abs(by_day['2021-01-01']>cost) * Math.PI + load_time.99"
+		Expect(sourceOf(expr)).To(MatchJSON(`{
+			"bucket_script": {
+				"buckets_path": {
+					"revenue": "revenue",
+					"orders__count": "orders>_count",
+					"by_day__2021_01_01___cost": "by_day['2021-01-01']>cost",
+					"load_time_99": "load_time.99"
+				},
+				"script": {"source": "(double) Math.round(Math.max(params.revenue, 0.0) / Math.max(params.orders__count, 1.0)) + Math.abs(params.by_day__2021_01_01___cost) * Math.PI + params.load_time_99"}
+			}
+		}`))
+	})
+
+	It("should keep the division of rounded values floating", func() {
+		Expect(scriptOf("round(a) / round(b)")).To(Equal("(double) Math.round(params.a) / (double) Math.round(params.b)"))
+		Expect(scriptOf("-round(a)")).To(Equal("-((double) Math.round(params.a))"))
+	})
+
+	It("should reuse the variables and avoid collisions of their names", func() {
+		Expect(sourceOf("a_b * a_b + a>b")).To(MatchJSON(`{
+			"bucket_script": {
+				"buckets_path": {"a_b": "a_b", "a_b_2": "a>b"},
+				"script": {"source": "params.a_b * params.a_b + params.a_b_2"}
+			}
+		}`))
+	})
+
+	It("should compile the quoted buckets paths", func() {
+		Expect(sourceOf("`pre-final.value` - final.value")).To(MatchJSON(`{
+			"bucket_script": {
+				"buckets_path": {"pre_final_value": "pre-final.value", "final_value": "final.value"},
+				"script": {"source": "params.pre_final_value - params.final_value"}
+			}
+		}`))
+		Expect(scriptOf("pre-final.value")).To(Equal("params.pre - params.final_value"))
+
+		Expect(sourceOf("`1x` + 2")).To(MatchJSON(`{
+			"bucket_script": {
+				"buckets_path": {"_1x": "1x"},
+				"script": {"source": "params._1x + 2.0"}
+			}
+		}`))
+	})
+
+	It("should reject invalid expressions", func() {
+		for _, expr := range []string{
+			"",
+			"a +",
+			"(a + b",
+			"a b",
+			"a; System.exit(0)",
+			"doc['price'].value",
+			"unknown(a)",
+			"max(a)",
+			"Math.max(a, b, c)",
+			"a == b",
+			"'text'",
+			"`a",
+			"``",
+			"`max`(a, b)",
+			"1/0",
+			"Math.PI * 2",
+		} {
+			_, err := aggretastic.BucketScriptExpr(expr)
+			Expect(errors.Is(err, aggretastic.ErrInvalidExpression)).To(BeTrue(), expr)
+		}
+	})
+})
@@ -1,7 +1,6 @@
 package aggretastic
 
 import (
-	"fmt"
 	"github.com/olivere/elastic/v7"
 )
 
@@ -13,6 +12,16 @@ var (
 	divideScript   = elastic.NewScript("params.a / params.b")
 	percentScript  = elastic.NewScript("(params.a / params.b) * 100")
 	valScript      = elastic.NewScript("params.a")
+
+	numDivideScriptSource = "params.a / params.num"
+
+	// safeGapPolicy passes the gaps to the script of safe helpers: with the default "skip"
+	// the buckets with missing values are skipped before the script runs, so the guards never fire
+	safeGapPolicy = "keep_values"
+
+	// the guards of safe helpers: Elasticsearch passes NaN for the empty metrics and null for unresolved paths
+	missingAGuard  = "params.a == null || Double.isNaN(params.a)"
+	missingABGuard = missingAGuard + " || params.b == null || Double.isNaN(params.b)"
 )
 
 // BucketsPath consists bucket's paths
@@ -65,11 +74,74 @@ func BucketScriptValAggregation(a string) *BucketScriptAggregation {
 	}, valScript)
 }
 
-// BucketScriptNumDivideAggregation performs math divide (between bucket value and number) operation
+// BucketScriptNumDivideAggregation performs math divide (between bucket value and number) operation.
+// The number is passed as the script param so the script source is the same for every number
 func BucketScriptNumDivideAggregation(a string, num float64) *BucketScriptAggregation {
 	return newBucketScriptAggregation(BucketsPath{
 		"a": a,
-	}, elastic.NewScript("params.a / "+fmt.Sprintf("%f", num)))
+	}, elastic.NewScript(numDivideScriptSource).Param("num", num))
+}
+
+// BucketScriptSafeAddAggregation performs math plus operation, the result is def when any value is missing (null or NaN).
+// The safe helpers set gap_policy "keep_values" so the buckets with missing values aren't skipped
+func BucketScriptSafeAddAggregation(a, b string, def float64) *BucketScriptAggregation {
+	return newBucketScriptAggregation(BucketsPath{
+		"a": a,
+		"b": b,
+	}, safeScript(missingABGuard, "params.a + params.b", def)).GapPolicy(safeGapPolicy)
+}
+
+// BucketScriptSafeSubtractAggregation performs math minus operation, the result is def when any value is missing (null or NaN)
+func BucketScriptSafeSubtractAggregation(a, b string, def float64) *BucketScriptAggregation {
+	return newBucketScriptAggregation(BucketsPath{
+		"a": a,
+		"b": b,
+	}, safeScript(missingABGuard, "params.a - params.b", def)).GapPolicy(safeGapPolicy)
+}
+
+// BucketScriptSafeMultiplyAggregation performs math multiply operation, the result is def when any value is missing (null or NaN)
+func BucketScriptSafeMultiplyAggregation(a, b string, def float64) *BucketScriptAggregation {
+	return newBucketScriptAggregation(BucketsPath{
+		"a": a,
+		"b": b,
+	}, safeScript(missingABGuard, "params.a * params.b", def)).GapPolicy(safeGapPolicy)
+}
+
+// BucketScriptSafeDivideAggregation performs math division operation, the result is def when any value is missing (null or NaN) or b is zero
+func BucketScriptSafeDivideAggregation(a, b string, def float64) *BucketScriptAggregation {
+	return newBucketScriptAggregation(BucketsPath{
+		"a": a,
+		"b": b,
+	}, safeScript(missingABGuard+" || params.b == 0", "params.a / params.b", def)).GapPolicy(safeGapPolicy)
+}
+
+// BucketScriptSafePercentAggregation performs math percent operation, the result is def when any value is missing (null or NaN) or b is zero
+func BucketScriptSafePercentAggregation(a, b string, def float64) *BucketScriptAggregation {
+	return newBucketScriptAggregation(BucketsPath{
+		"a": a,
+		"b": b,
+	}, safeScript(missingABGuard+" || params.b == 0", "(params.a / params.b) * 100", def)).GapPolicy(safeGapPolicy)
+}
+
+// BucketScriptSafeValAggregation returns the value, the result is def when the value is missing (null or NaN)
+func BucketScriptSafeValAggregation(a string, def float64) *BucketScriptAggregation {
+	return newBucketScriptAggregation(BucketsPath{
+		"a": a,
+	}, safeScript(missingAGuard, "params.a", def)).GapPolicy(safeGapPolicy)
+}
+
+// BucketScriptSafeNumDivideAggregation performs math divide (between bucket value and number) operation,
+// the result is def when the value is missing (null or NaN) or the number is zero
+func BucketScriptSafeNumDivideAggregation(a string, num, def float64) *BucketScriptAggregation {
+	return newBucketScriptAggregation(BucketsPath{
+		"a": a,
+	}, safeScript(missingAGuard+" || params.num == 0", numDivideScriptSource, def).Param("num", num)).GapPolicy(safeGapPolicy)
+}
+
+// safeScript returns the script which evaluates the expression unless the guard is true, otherwise it returns def.
+// The default value is passed as the script param so the script source doesn't depend on it
+func safeScript(guard, expr string, def float64) *elastic.Script {
+	return elastic.NewScript(guard+" ? params.defaultValue : "+expr).Param("defaultValue", def)
 }
 
 // newBucketScriptAggregation is a private function, constructor of elastic.BucketScriptAggregation
@@ -0,0 +1,108 @@
+package aggretastic_test
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aahainc/aggretastic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bucket script helpers", func() {
+
+	sourceOf := func(agg aggretastic.Aggregation) string {
+		src, err := agg.Source()
+		Expect(err).NotTo(HaveOccurred())
+		b, err := json.Marshal(src)
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	It("should pass the number of NumDivide as the script param", func() {
+		Expect(sourceOf(aggretastic.BucketScriptNumDivideAggregation("revenue", 0.1234567891))).To(MatchJSON(`{
+			"bucket_script": {
+				"buckets_path": {"a": "revenue"},
+				"script": {"source": "params.a / params.num", "params": {"num": 0.1234567891}}
+			}
+		}`))
+	})
+
+	// guardOf returns the conditions of the guard of safe script and the params
+	guardOf := func(agg aggretastic.Aggregation) ([]string, map[string]interface{}) {
+		var src struct {
+			BucketScript struct {
+				Script struct {
+					Source string                 `json:"source"`
+					Params map[string]interface{} `json:"params"`
+				} `json:"script"`
+			} `json:"bucket_script"`
+		}
+		Expect(json.Unmarshal([]byte(sourceOf(agg)), &src)).To(Succeed())
+
+		parts := strings.SplitN(src.BucketScript.Script.Source, " ? params.defaultValue : ", 2)
+		Expect(parts).To(HaveLen(2), src.BucketScript.Script.Source)
+		return strings.Split(parts[0], " || "), src.BucketScript.Script.Params
+	}
+
+	missing := func(names ...string) []string {
+		conditions := make([]string, 0)
+		for _, name := range names {
+			// null is checked first: Double.isNaN(null) fails
+			conditions = append(conditions, "params."+name+" == null", "Double.isNaN(params."+name+")")
+		}
+		return conditions
+	}
+
+	It("should guard the operations against null and NaN values", func() {
+		for _, agg := range []aggretastic.Aggregation{
+			aggretastic.BucketScriptSafeAddAggregation("revenue", "cost", 0),
+			aggretastic.BucketScriptSafeSubtractAggregation("revenue", "cost", 0),
+			aggretastic.BucketScriptSafeMultiplyAggregation("revenue", "cost", 0),
+		} {
+			guard, params := guardOf(agg)
+			Expect(guard).To(Equal(missing("a", "b")))
+			Expect(params).To(HaveKeyWithValue("defaultValue", BeEquivalentTo(0)))
+		}
+
+		guard, _ := guardOf(aggretastic.BucketScriptSafeValAggregation("revenue", -1))
+		Expect(guard).To(Equal(missing("a")))
+	})
+
+	It("should guard the division against null and NaN values and zero", func() {
+		for _, agg := range []aggretastic.Aggregation{
+			aggretastic.BucketScriptSafeDivideAggregation("revenue", "orders", 0),
+			aggretastic.BucketScriptSafePercentAggregation("paid", "total", -1),
+		} {
+			guard, _ := guardOf(agg)
+			Expect(guard).To(Equal(append(missing("a", "b"), "params.b == 0")))
+		}
+
+		guard, params := guardOf(aggretastic.BucketScriptSafeNumDivideAggregation("revenue", 1000, 0))
+		Expect(guard).To(Equal(append(missing("a"), "params.num == 0")))
+		Expect(params).To(HaveKeyWithValue("num", BeEquivalentTo(1000)))
+	})
+
+	It("should keep the gaps for the guards", func() {
+		for _, agg := range []aggretastic.Aggregation{
+			aggretastic.BucketScriptSafeAddAggregation("a", "b", 0),
+			aggretastic.BucketScriptSafeSubtractAggregation("a", "b", 0),
+			aggretastic.BucketScriptSafeMultiplyAggregation("a", "b", 0),
+			aggretastic.BucketScriptSafeDivideAggregation("a", "b", 0),
+			aggretastic.BucketScriptSafePercentAggregation("a", "b", 0),
+			aggretastic.BucketScriptSafeValAggregation("a", 0),
+			aggretastic.BucketScriptSafeNumDivideAggregation("a", 10, 0),
+		} {
+			src, err := agg.Source()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(src).To(HaveKeyWithValue("bucket_script", HaveKeyWithValue("gap_policy", "keep_values")))
+		}
+	})
+
+	It("should not share the params between aggregations", func() {
+		a := aggretastic.BucketScriptSafeDivideAggregation("revenue", "orders", 0)
+		b := aggretastic.BucketScriptSafeDivideAggregation("revenue", "orders", 1)
+		Expect(sourceOf(a)).To(ContainSubstring(`"defaultValue":0`))
+		Expect(sourceOf(b)).To(ContainSubstring(`"defaultValue":1`))
+	})
+})
@@ -83,6 +83,17 @@ func (a *BucketScriptAggregation) AddBucketsPath(name, path string) *BucketScrip
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *BucketScriptAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.bucketsPathsMap = cloneStringsMap(a.bucketsPathsMap)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *BucketScriptAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
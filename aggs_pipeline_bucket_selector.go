@@ -82,6 +82,17 @@ func (a *BucketSelectorAggregation) AddBucketsPath(name, path string) *BucketSel
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *BucketSelectorAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.script = cloneScript(a.script)
+	c.bucketsPathsMap = cloneStringsMap(a.bucketsPathsMap)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *BucketSelectorAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
@@ -83,6 +83,16 @@ func (a *BucketSortAggregation) Meta(meta map[string]interface{}) *BucketSortAgg
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *BucketSortAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.sorters = append(make([]elastic.Sorter, 0, len(a.sorters)), a.sorters...)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *BucketSortAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
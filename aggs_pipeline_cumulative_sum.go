@@ -44,6 +44,16 @@ func (a *CumulativeSumAggregation) BucketsPath(bucketsPaths ...string) *Cumulati
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *CumulativeSumAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.bucketsPaths = cloneStrings(a.bucketsPaths)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *CumulativeSumAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
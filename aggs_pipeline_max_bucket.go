@@ -65,6 +65,16 @@ func (a *MaxBucketAggregation) BucketsPath(bucketsPaths ...string) *MaxBucketAgg
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *MaxBucketAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.bucketsPaths = cloneStrings(a.bucketsPaths)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *MaxBucketAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
@@ -97,6 +97,17 @@ func (a *MovAvgAggregation) BucketsPath(bucketsPaths ...string) *MovAvgAggregati
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *MovAvgAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.model = cloneMovAvgModel(a.model)
+	c.bucketsPaths = cloneStrings(a.bucketsPaths)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *MovAvgAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
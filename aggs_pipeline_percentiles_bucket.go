@@ -69,6 +69,17 @@ func (p *PercentilesBucketAggregation) BucketsPath(bucketsPaths ...string) *Perc
 	return p
 }
 
+// Clone returns a deep copy of the aggregation
+func (p *PercentilesBucketAggregation) Clone() Aggregation {
+	c := *p
+	c.notInjectable = p.notInjectable.cloneFor(&c)
+	c.percents = cloneFloat64s(p.percents)
+	c.bucketsPaths = cloneStrings(p.bucketsPaths)
+	c.meta = cloneMap(p.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (p *PercentilesBucketAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
@@ -72,6 +72,16 @@ func (a *SerialDiffAggregation) BucketsPath(bucketsPaths ...string) *SerialDiffA
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *SerialDiffAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.bucketsPaths = cloneStrings(a.bucketsPaths)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *SerialDiffAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
@@ -64,6 +64,16 @@ func (s *StatsBucketAggregation) BucketsPath(bucketsPaths ...string) *StatsBucke
 	return s
 }
 
+// Clone returns a deep copy of the aggregation
+func (s *StatsBucketAggregation) Clone() Aggregation {
+	c := *s
+	c.notInjectable = s.notInjectable.cloneFor(&c)
+	c.bucketsPaths = cloneStrings(s.bucketsPaths)
+	c.meta = cloneMap(s.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (s *StatsBucketAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})
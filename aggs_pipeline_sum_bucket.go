@@ -64,6 +64,16 @@ func (a *SumBucketAggregation) BucketsPath(bucketsPaths ...string) *SumBucketAgg
 	return a
 }
 
+// Clone returns a deep copy of the aggregation
+func (a *SumBucketAggregation) Clone() Aggregation {
+	c := *a
+	c.notInjectable = a.notInjectable.cloneFor(&c)
+	c.bucketsPaths = cloneStrings(a.bucketsPaths)
+	c.meta = cloneMap(a.meta)
+
+	return &c
+}
+
 // Source returns the a JSON-serializable interface.
 func (a *SumBucketAggregation) Source() (interface{}, error) {
 	source := make(map[string]interface{})